@@ -0,0 +1,194 @@
+package go_world
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"math"
+)
+
+// Sphere the flock steers around
+type FlockObstacle struct {
+	Center mgl32.Vec3
+	Radius float32
+}
+
+// Reynolds style flocking of all particles of a ParticleSystem. Add it with
+// ParticleSystem.AddInteractionHandler.
+type Flock struct {
+	SeparationWeight float32
+	AlignmentWeight  float32
+	CohesionWeight   float32
+	ObstacleWeight   float32
+	GoalWeight       float32
+
+	PerceptionRadius float32
+	SeparationRadius float32
+	// Full viewing angle in radians, 2π sees all neighbours
+	FieldOfView float32
+
+	MaxSpeed float32
+	MaxForce float32
+
+	obstacles []FlockObstacle
+	goal      *mgl32.Vec3
+	grid      *spatialGrid
+	steering  []mgl32.Vec3
+}
+
+func NewFlock(perceptionRadius float32) *Flock {
+	flock := new(Flock)
+	flock.SeparationWeight = 1.5
+	flock.AlignmentWeight = 1
+	flock.CohesionWeight = 1
+	flock.ObstacleWeight = 2
+	flock.GoalWeight = 0.5
+	flock.PerceptionRadius = perceptionRadius
+	flock.SeparationRadius = perceptionRadius / 2
+	flock.FieldOfView = 2 * math.Pi
+	flock.MaxSpeed = 1
+	flock.MaxForce = 0.5
+	return flock
+}
+
+func (f *Flock) AddObstacle(center mgl32.Vec3, radius float32) *Flock {
+	f.obstacles = append(f.obstacles, FlockObstacle{center, radius})
+	return f
+}
+
+func (f *Flock) Obstacles() []FlockObstacle {
+	return f.obstacles
+}
+
+func (f *Flock) SetGoal(goal mgl32.Vec3) *Flock {
+	f.goal = &goal
+	return f
+}
+
+func (f *Flock) ClearGoal() *Flock {
+	f.goal = nil
+	return f
+}
+
+func (f *Flock) Apply(particles []*Particle, time_delta float32) {
+	// Boids that perceive nothing ignore each other and the obstacles, the
+	// grid would need cells of no size
+	if f.PerceptionRadius <= 0 {
+		f.grid = nil
+	} else {
+		if f.grid == nil || f.grid.cellSize != f.PerceptionRadius {
+			f.grid = newSpatialGrid(f.PerceptionRadius)
+		}
+		f.grid.build(particles)
+	}
+
+	// Steering is computed for all boids before any velocity changes
+	f.steering = f.steering[:0]
+	for _, p := range particles {
+		f.steering = append(f.steering, f.steer(p))
+	}
+
+	for i, p := range particles {
+		dv := f.steering[i].Mul(time_delta / p.Mass())
+		p.ApplyForce(dv[0], dv[1], dv[2])
+		f.limitSpeed(p)
+	}
+}
+
+func (f *Flock) steer(p *Particle) mgl32.Vec3 {
+	position := p.Position()
+	velocity := p.Velocity()
+
+	var separation, alignment, cohesion mgl32.Vec3
+	neighbours := 0
+
+	f.forEachNeighbour(position, func(other *Particle) {
+		if other == p || !f.sees(p, other) {
+			return
+		}
+		offset := position.Sub(other.Position())
+		distance := offset.Len()
+		if distance > f.PerceptionRadius {
+			return
+		}
+
+		if distance > 0 && distance < f.SeparationRadius {
+			separation = separation.Add(offset.Mul(1 / (distance * distance)))
+		}
+		alignment = alignment.Add(other.Velocity())
+		cohesion = cohesion.Add(other.Position())
+		neighbours++
+	})
+
+	var force mgl32.Vec3
+	if neighbours > 0 {
+		n := float32(neighbours)
+		force = force.Add(f.seekDirection(separation, velocity).Mul(f.SeparationWeight))
+		force = force.Add(f.seekDirection(alignment.Mul(1/n), velocity).Mul(f.AlignmentWeight))
+		force = force.Add(f.seekDirection(cohesion.Mul(1/n).Sub(position), velocity).Mul(f.CohesionWeight))
+	}
+	force = force.Add(f.avoidObstacles(position, velocity).Mul(f.ObstacleWeight))
+	if f.goal != nil {
+		force = force.Add(f.seekDirection(f.goal.Sub(position), velocity).Mul(f.GoalWeight))
+	}
+	return limitLength(force, f.MaxForce)
+}
+
+func (f *Flock) forEachNeighbour(position mgl32.Vec3, fn func(*Particle)) {
+	if f.grid != nil {
+		f.grid.forEachNear(position, f.PerceptionRadius, fn)
+	}
+}
+
+func (f *Flock) avoidObstacles(position, velocity mgl32.Vec3) mgl32.Vec3 {
+	var force mgl32.Vec3
+	if f.PerceptionRadius <= 0 {
+		return force
+	}
+	for _, o := range f.obstacles {
+		away := position.Sub(o.Center)
+		clearance := away.Len() - o.Radius
+		if clearance > f.PerceptionRadius {
+			continue
+		}
+		urgency := 1 - mgl32.Clamp(clearance/f.PerceptionRadius, 0, 1)
+		force = force.Add(f.seekDirection(away, velocity).Mul(urgency))
+	}
+	return force
+}
+
+// Reynolds steering: desired velocity at max speed minus current velocity
+func (f *Flock) seekDirection(direction, velocity mgl32.Vec3) mgl32.Vec3 {
+	if direction.Len() == 0 {
+		return mgl32.Vec3{}
+	}
+	desired := direction.Normalize().Mul(f.MaxSpeed)
+	return limitLength(desired.Sub(velocity), f.MaxForce)
+}
+
+func (f *Flock) sees(p, other *Particle) bool {
+	if f.FieldOfView >= 2*math.Pi {
+		return true
+	}
+	velocity := p.Velocity()
+	offset := other.Position().Sub(p.Position())
+	if velocity.Len() == 0 || offset.Len() == 0 {
+		return true
+	}
+	cosine := velocity.Normalize().Dot(offset.Normalize())
+	return cosine >= float32(math.Cos(float64(f.FieldOfView/2)))
+}
+
+func (f *Flock) limitSpeed(p *Particle) {
+	if f.MaxSpeed <= 0 {
+		return
+	}
+	v := limitLength(p.Velocity(), f.MaxSpeed)
+	p.SetVelocity(v[0], v[1], v[2])
+}
+
+func limitLength(v mgl32.Vec3, max float32) mgl32.Vec3 {
+	length := v.Len()
+	if max > 0 && length > max {
+		return v.Mul(max / length)
+	}
+	return v
+}
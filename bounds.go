@@ -0,0 +1,199 @@
+package go_world
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"math"
+)
+
+// Axis aligned bounding box
+type AABB struct {
+	Min mgl32.Vec3
+	Max mgl32.Vec3
+}
+
+// Smallest box containing all points, the zero box for none
+func NewAABB(points ...mgl32.Vec3) AABB {
+	if len(points) == 0 {
+		return AABB{}
+	}
+	box := AABB{points[0], points[0]}
+	for _, p := range points[1:] {
+		box = box.Extend(p)
+	}
+	return box
+}
+
+func (b AABB) Center() mgl32.Vec3 {
+	return b.Min.Add(b.Max).Mul(0.5)
+}
+
+func (b AABB) Size() mgl32.Vec3 {
+	return b.Max.Sub(b.Min)
+}
+
+func (b AABB) Extend(p mgl32.Vec3) AABB {
+	for i := 0; i < 3; i++ {
+		b.Min[i] = float32(math.Min(float64(b.Min[i]), float64(p[i])))
+		b.Max[i] = float32(math.Max(float64(b.Max[i]), float64(p[i])))
+	}
+	return b
+}
+
+func (b AABB) Union(other AABB) AABB {
+	return b.Extend(other.Min).Extend(other.Max)
+}
+
+func (b AABB) Contains(p mgl32.Vec3) bool {
+	for i := 0; i < 3; i++ {
+		if p[i] < b.Min[i] || p[i] > b.Max[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (b AABB) Intersects(other AABB) bool {
+	for i := 0; i < 3; i++ {
+		if b.Max[i] < other.Min[i] || other.Max[i] < b.Min[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Distance along the ray to where it enters the box, 0 if origin is inside
+func (b AABB) IntersectRay(origin, direction mgl32.Vec3) (float32, bool) {
+	tEnter, _, ok := rayBoxIntersection(origin, direction, b.Min, b.Max)
+	if !ok {
+		return 0, false
+	}
+	return float32(math.Max(float64(tEnter), 0)), true
+}
+
+// Box around the eight transformed corners
+func (b AABB) Transform(m mgl32.Mat4) AABB {
+	var corners [8]mgl32.Vec3
+	for i := range corners {
+		corner := b.Min
+		for axis := 0; axis < 3; axis++ {
+			if i&(1<<uint(axis)) != 0 {
+				corner[axis] = b.Max[axis]
+			}
+		}
+		corners[i] = mgl32.TransformCoordinate(corner, m)
+	}
+	return NewAABB(corners[:]...)
+}
+
+type BoundingSphere struct {
+	Center mgl32.Vec3
+	Radius float32
+}
+
+func (s BoundingSphere) Contains(p mgl32.Vec3) bool {
+	return p.Sub(s.Center).Len() <= s.Radius
+}
+
+func (s BoundingSphere) Intersects(other BoundingSphere) bool {
+	return other.Center.Sub(s.Center).Len() <= s.Radius+other.Radius
+}
+
+// Sphere around the transformed sphere, the radius grows with the largest
+// scale of m
+func (s BoundingSphere) Transform(m mgl32.Mat4) BoundingSphere {
+	scale := math.Max(float64(m.Col(0).Vec3().Len()),
+		math.Max(float64(m.Col(1).Vec3().Len()), float64(m.Col(2).Vec3().Len())))
+	return BoundingSphere{mgl32.TransformCoordinate(s.Center, m), s.Radius * float32(scale)}
+}
+
+// Bounds of all vertices, computed on first use
+func (g *Geometry) Bounds() AABB {
+	g.updateBounds()
+	return g.bounds
+}
+
+// Sphere containing all vertices, close to but not always the smallest one
+func (g *Geometry) BoundingSphere() BoundingSphere {
+	g.updateBounds()
+	return g.sphere
+}
+
+// Has to be called by everything changing the vertices of an existing
+// geometry
+func (g *Geometry) invalidateBounds() {
+	g.boundsValid = false
+}
+
+func (g *Geometry) updateBounds() {
+	if g.boundsValid {
+		return
+	}
+	points := make([]mgl32.Vec3, g.vertexCount())
+	for i := range points {
+		points[i] = mgl32.Vec3{g.vertices[i*3], g.vertices[i*3+1], g.vertices[i*3+2]}
+	}
+	g.bounds = NewAABB(points...)
+	g.sphere = ritterSphere(points)
+	g.boundsValid = true
+}
+
+// Ritter's approximation: a sphere through two distant points, grown until
+// it contains every point
+func ritterSphere(points []mgl32.Vec3) BoundingSphere {
+	if len(points) == 0 {
+		return BoundingSphere{}
+	}
+	farthest := func(from mgl32.Vec3) mgl32.Vec3 {
+		best, distance := from, float32(-1)
+		for _, p := range points {
+			if d := p.Sub(from).Len(); d > distance {
+				best, distance = p, d
+			}
+		}
+		return best
+	}
+	a := farthest(points[0])
+	b := farthest(a)
+	sphere := BoundingSphere{a.Add(b).Mul(0.5), b.Sub(a).Len() / 2}
+
+	for _, p := range points {
+		d := p.Sub(sphere.Center).Len()
+		if d <= sphere.Radius {
+			continue
+		}
+		radius := (sphere.Radius + d) / 2
+		sphere.Center = sphere.Center.Add(p.Sub(sphere.Center).Mul((radius - sphere.Radius) / d))
+		sphere.Radius = radius
+	}
+	return sphere
+}
+
+// Bounds of the geometry in world space. Objects without geometry are a
+// point at their origin.
+func (o *Object) WorldBounds() AABB {
+	m := o.ModelMatrix()
+	if o.geometry == nil {
+		return NewAABB(mgl32.TransformCoordinate(mgl32.Vec3{}, m))
+	}
+	return o.geometry.Bounds().Transform(m)
+}
+
+func (o *Object) WorldBoundingSphere() BoundingSphere {
+	m := o.ModelMatrix()
+	if o.geometry == nil {
+		return BoundingSphere{Center: mgl32.TransformCoordinate(mgl32.Vec3{}, m)}
+	}
+	return o.geometry.BoundingSphere().Transform(m)
+}
+
+// Union of the world bounds of all objects, false for an empty scene
+func (s *Scene) Bounds() (AABB, bool) {
+	if len(s.objects) == 0 {
+		return AABB{}, false
+	}
+	bounds := s.objects[0].WorldBounds()
+	for _, o := range s.objects[1:] {
+		bounds = bounds.Union(o.WorldBounds())
+	}
+	return bounds, true
+}
@@ -6,26 +6,53 @@ import (
 )
 
 type Camera struct {
-    viewMatrix mgl32.Mat4
-    program    uint32
+    viewMatrix       mgl32.Mat4
+    projectionMatrix mgl32.Mat4
+    program          uint32
 }
 
 func NewCamera(program uint32) *Camera {
     camera := new(Camera)
-	
+    camera.program = program
+
 	//projection matrix
-	projection := mgl32.Perspective(mgl32.DegToRad(45.0), float32(windowWidth)/windowHeight, 0.1, 10.0)
-	projectionUniform := gl.GetUniformLocation(program, gl.Str("projection\x00"))
-	gl.UniformMatrix4fv(projectionUniform, 1, false, &projection[0])
-	
+	camera.SetPerspective(mgl32.DegToRad(45.0), float32(windowWidth)/windowHeight, 0.1, 10.0)
+
 	// camera position
-	viewMatrix := mgl32.LookAtV(mgl32.Vec3{0, 0, 3}, mgl32.Vec3{0, 0, 0}, mgl32.Vec3{0, 1, 0})
-	cameraUniform := gl.GetUniformLocation(program, gl.Str("camera\x00"))
-	gl.UniformMatrix4fv(cameraUniform, 1, false, &viewMatrix[0])
+	camera.LookAt(mgl32.Vec3{0, 0, 3}, mgl32.Vec3{0, 0, 0}, mgl32.Vec3{0, 1, 0})
 
-	camera.viewMatrix = viewMatrix
-    camera.program = program
     return camera
 }
 
+func (c *Camera) SetPerspective(fovy, aspect, near, far float32) *Camera {
+	c.projectionMatrix = mgl32.Perspective(fovy, aspect, near, far)
+	projectionUniform := gl.GetUniformLocation(c.program, gl.Str("projection\x00"))
+	gl.UniformMatrix4fv(projectionUniform, 1, false, &c.projectionMatrix[0])
+    return c
+}
+
+func (c *Camera) LookAt(eye, center, up mgl32.Vec3) *Camera {
+	c.viewMatrix = mgl32.LookAtV(eye, center, up)
+	cameraUniform := gl.GetUniformLocation(c.program, gl.Str("camera\x00"))
+	gl.UniformMatrix4fv(cameraUniform, 1, false, &c.viewMatrix[0])
+    return c
+}
+
+func (c *Camera) ViewMatrix() mgl32.Mat4 {
+    return c.viewMatrix
+}
 
+func (c *Camera) ProjectionMatrix() mgl32.Mat4 {
+    return c.projectionMatrix
+}
+
+// Ray through the window coordinates x, y (origin top left, as reported by
+// GLFW) of a window with the given size. Returns the point on the near plane
+// and the normalized direction.
+func (c *Camera) ScreenRay(x, y float32, width, height int) (mgl32.Vec3, mgl32.Vec3) {
+	window := mgl32.Vec3{x, float32(height) - y, 0}
+	near, _ := mgl32.UnProject(window, c.viewMatrix, c.projectionMatrix, 0, 0, width, height)
+	window[2] = 1
+	far, _ := mgl32.UnProject(window, c.viewMatrix, c.projectionMatrix, 0, 0, width, height)
+	return near, far.Sub(near).Normalize()
+}
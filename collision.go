@@ -0,0 +1,100 @@
+package go_world
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Resolves overlapping particles as colliding spheres. A restitution of 1
+// is perfectly elastic, 0 perfectly inelastic.
+type ElasticCollisionHandler struct {
+	Restitution float32
+	Box         *PeriodicBox
+	// Optional shared neighbour list, its cutoff has to cover the largest
+	// sum of two radii. Without one a grid is built every step.
+	Neighbours *NeighbourList
+	grid       *spatialGrid
+}
+
+func NewElasticCollisionHandler(restitution float32) *ElasticCollisionHandler {
+	return &ElasticCollisionHandler{Restitution: restitution}
+}
+
+func (ch *ElasticCollisionHandler) SetPeriodicBox(box *PeriodicBox) {
+	ch.Box = box
+}
+
+func (ch *ElasticCollisionHandler) Apply(particles []*Particle) {
+	if ch.Neighbours != nil {
+		ch.Neighbours.SetPeriodicBox(ch.Box)
+		ch.Neighbours.Update(particles)
+		ch.Neighbours.ForEachPair(ch.collide)
+		return
+	}
+
+	maxRadius := float32(0)
+	for _, p := range particles {
+		if p.Radius() > maxRadius {
+			maxRadius = p.Radius()
+		}
+	}
+	if maxRadius == 0 {
+		return
+	}
+	if ch.grid == nil || ch.grid.cellSize != 2*maxRadius {
+		ch.grid = newSpatialGrid(2 * maxRadius)
+	}
+	ch.grid.build(particles)
+
+	index := make(map[*Particle]int, len(particles))
+	for i, p := range particles {
+		index[p] = i
+	}
+
+	for i, a := range particles {
+		ch.grid.forEachNearPeriodic(ch.Box, a.Position(), a.Radius()+maxRadius, func(b *Particle) {
+			// Every pair is handled once
+			if index[b] <= i {
+				return
+			}
+			ch.collide(a, b)
+		})
+	}
+}
+
+func (ch *ElasticCollisionHandler) collide(a, b *Particle) {
+	offset := ch.Box.Displacement(a.Position(), b.Position())
+	distance := offset.Len()
+	overlap := a.Radius() + b.Radius() - distance
+	if overlap <= 0 || distance == 0 {
+		return
+	}
+	normal := offset.Mul(1 / distance)
+	resolveContact(a, b, normal, overlap, ch.Restitution)
+}
+
+// Pushes a and b apart along normal (pointing from a to b) and exchanges the
+// impulse of a collision with the given restitution. Pinned and kinematic
+// particles are not moved.
+func resolveContact(a, b *Particle, normal mgl32.Vec3, overlap, restitution float32) {
+	invA := a.InverseMass()
+	invB := b.InverseMass()
+	invSum := invA + invB
+	if invSum == 0 {
+		return
+	}
+
+	pa := a.Position().Sub(normal.Mul(overlap * invA / invSum))
+	pb := b.Position().Add(normal.Mul(overlap * invB / invSum))
+	a.SetPosition(pa[0], pa[1], pa[2])
+	b.SetPosition(pb[0], pb[1], pb[2])
+
+	approach := b.Velocity().Sub(a.Velocity()).Dot(normal)
+	if approach >= 0 {
+		return
+	}
+	impulse := -(1 + restitution) * approach / invSum
+	va := a.Velocity().Sub(normal.Mul(impulse * invA))
+	vb := b.Velocity().Add(normal.Mul(impulse * invB))
+	a.SetVelocity(va[0], va[1], va[2])
+	b.SetVelocity(vb[0], vb[1], vb[2])
+}
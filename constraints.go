@@ -0,0 +1,93 @@
+package go_world
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Keeps particles inside an axis aligned box, reflecting them off its walls.
+type BoxConstraint struct {
+	Min         mgl32.Vec3
+	Max         mgl32.Vec3
+	Restitution float32
+}
+
+func NewBoxConstraint(min, max mgl32.Vec3) *BoxConstraint {
+	return &BoxConstraint{Min: min, Max: max, Restitution: 1}
+}
+
+func (c *BoxConstraint) Apply(p *Particle) {
+	if p.InverseMass() == 0 {
+		return
+	}
+	position := p.Position()
+	velocity := p.Velocity()
+	r := p.Radius()
+
+	for i := 0; i < 3; i++ {
+		// Flat boxes only constrain the remaining axes
+		if c.Max[i]-c.Min[i] < 2*r {
+			continue
+		}
+		if position[i]-r < c.Min[i] {
+			position[i] = c.Min[i] + r
+			if velocity[i] < 0 {
+				velocity[i] = -velocity[i] * c.Restitution
+			}
+		} else if position[i]+r > c.Max[i] {
+			position[i] = c.Max[i] - r
+			if velocity[i] > 0 {
+				velocity[i] = -velocity[i] * c.Restitution
+			}
+		}
+	}
+
+	p.SetPosition(position[0], position[1], position[2])
+	p.SetVelocity(velocity[0], velocity[1], velocity[2])
+}
+
+// Holds two particles at a fixed distance like a rigid rod, e.g. cloth
+// springs or a pendulum hanging from a pinned pivot. Stiffness is the
+// fraction of the error corrected per step, 1 enforces the length exactly.
+type DistanceConstraint struct {
+	A         *Particle
+	B         *Particle
+	Length    float32
+	Stiffness float32
+}
+
+// Uses the current distance of a and b as length
+func NewDistanceConstraint(a, b *Particle) *DistanceConstraint {
+	length := b.Position().Sub(a.Position()).Len()
+	return &DistanceConstraint{A: a, B: b, Length: length, Stiffness: 1}
+}
+
+func (c *DistanceConstraint) Apply(p *Particle) {
+	// Called for every particle, the pair is handled once
+	if p != c.A {
+		return
+	}
+	invA, invB := c.A.InverseMass(), c.B.InverseMass()
+	invSum := invA + invB
+	if invSum == 0 {
+		return
+	}
+	offset := c.B.Position().Sub(c.A.Position())
+	distance := offset.Len()
+	if distance == 0 {
+		return
+	}
+	normal := offset.Mul(1 / distance)
+
+	correction := normal.Mul((distance - c.Length) * c.Stiffness / invSum)
+	pa := c.A.Position().Add(correction.Mul(invA))
+	pb := c.B.Position().Sub(correction.Mul(invB))
+	c.A.SetPosition(pa[0], pa[1], pa[2])
+	c.B.SetPosition(pb[0], pb[1], pb[2])
+
+	// The rod carries no relative velocity along itself
+	stretch := c.B.Velocity().Sub(c.A.Velocity()).Dot(normal) * c.Stiffness / invSum
+	va := c.A.Velocity().Add(normal.Mul(stretch * invA))
+	vb := c.B.Velocity().Sub(normal.Mul(stretch * invB))
+	c.A.SetVelocity(va[0], va[1], va[2])
+	c.B.SetVelocity(vb[0], vb[1], vb[2])
+}
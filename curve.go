@@ -0,0 +1,524 @@
+package go_world
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"math"
+	"sort"
+)
+
+// Parametric curve, t runs from 0 at the start to 1 at the end
+type Curve interface {
+	Point(t float32) mgl32.Vec3
+	// Derivative with respect to t, not normalized
+	Tangent(t float32) mgl32.Vec3
+}
+
+// Single Bézier curve whose degree is one less than the number of control
+// points. It passes through the first and the last point.
+type BezierCurve struct {
+	points []mgl32.Vec3
+}
+
+func NewBezierCurve(points ...mgl32.Vec3) *BezierCurve {
+	curve := new(BezierCurve)
+	curve.points = points
+	return curve
+}
+
+func (c *BezierCurve) Points() []mgl32.Vec3 {
+	return c.points
+}
+
+func (c *BezierCurve) Point(t float32) mgl32.Vec3 {
+	return deCasteljau(c.points, t)
+}
+
+func (c *BezierCurve) Tangent(t float32) mgl32.Vec3 {
+	n := len(c.points) - 1
+	if n < 1 {
+		return mgl32.Vec3{}
+	}
+	// The derivative is a Bézier curve over the differences of the points
+	differences := make([]mgl32.Vec3, n)
+	for i := range differences {
+		differences[i] = c.points[i+1].Sub(c.points[i]).Mul(float32(n))
+	}
+	return deCasteljau(differences, t)
+}
+
+func deCasteljau(points []mgl32.Vec3, t float32) mgl32.Vec3 {
+	if len(points) == 0 {
+		return mgl32.Vec3{}
+	}
+	work := append([]mgl32.Vec3(nil), points...)
+	for n := len(work) - 1; n > 0; n-- {
+		for i := 0; i < n; i++ {
+			work[i] = work[i].Add(work[i+1].Sub(work[i]).Mul(t))
+		}
+	}
+	return work[0]
+}
+
+// Control points shared by the piecewise cubic curves, one cubic segment
+// per span between points
+type spline struct {
+	points []mgl32.Vec3
+	closed bool
+}
+
+// Segment of t and the parameter within it
+func locateSegment(t float32, segments int) (int, float32) {
+	u := float32(math.Max(0, math.Min(1, float64(t)))) * float32(segments)
+	i := int(u)
+	if i >= segments {
+		i = segments - 1
+	}
+	return i, u - float32(i)
+}
+
+func (s *spline) wrap(i int) mgl32.Vec3 {
+	n := len(s.points)
+	return s.points[((i%n)+n)%n]
+}
+
+// Uniform Catmull-Rom spline, passing through every point. Open curves are
+// extended beyond their ends by mirroring the neighbouring point.
+type CatmullRomCurve struct {
+	spline
+}
+
+func NewCatmullRomCurve(points ...mgl32.Vec3) *CatmullRomCurve {
+	curve := new(CatmullRomCurve)
+	curve.points = points
+	return curve
+}
+
+// Closed curves return from the last point to the first one
+func (c *CatmullRomCurve) SetClosed(closed bool) *CatmullRomCurve {
+	c.closed = closed
+	return c
+}
+
+func (c *CatmullRomCurve) Segments() int {
+	if c.closed {
+		return len(c.points)
+	}
+	return len(c.points) - 1
+}
+
+func (c *CatmullRomCurve) control(i int) mgl32.Vec3 {
+	n := len(c.points)
+	switch {
+	case c.closed:
+		return c.wrap(i)
+	case i < 0:
+		return c.points[0].Mul(2).Sub(c.points[1])
+	case i >= n:
+		return c.points[n-1].Mul(2).Sub(c.points[n-2])
+	}
+	return c.points[i]
+}
+
+func (c *CatmullRomCurve) segment(t float32) ([4]mgl32.Vec3, float32, int) {
+	segments := c.Segments()
+	i, s := locateSegment(t, segments)
+	return [4]mgl32.Vec3{c.control(i - 1), c.control(i), c.control(i + 1), c.control(i + 2)}, s, segments
+}
+
+func (c *CatmullRomCurve) Point(t float32) mgl32.Vec3 {
+	if len(c.points) < 2 {
+		return deCasteljau(c.points, t)
+	}
+	p, s, _ := c.segment(t)
+	s2, s3 := s*s, s*s*s
+	return p[0].Mul(-s3 + 2*s2 - s).
+		Add(p[1].Mul(3*s3 - 5*s2 + 2)).
+		Add(p[2].Mul(-3*s3 + 4*s2 + s)).
+		Add(p[3].Mul(s3 - s2)).
+		Mul(0.5)
+}
+
+func (c *CatmullRomCurve) Tangent(t float32) mgl32.Vec3 {
+	if len(c.points) < 2 {
+		return mgl32.Vec3{}
+	}
+	p, s, segments := c.segment(t)
+	s2 := s * s
+	return p[0].Mul(-3*s2 + 4*s - 1).
+		Add(p[1].Mul(9*s2 - 10*s)).
+		Add(p[2].Mul(-9*s2 + 8*s + 1)).
+		Add(p[3].Mul(3*s2 - 2*s)).
+		Mul(0.5 * float32(segments))
+}
+
+// Uniform cubic B-spline. It is smoother than a Catmull-Rom spline but only
+// approaches the points. Open curves repeat their end points so that they
+// start and end on them.
+type BSplineCurve struct {
+	spline
+}
+
+func NewBSplineCurve(points ...mgl32.Vec3) *BSplineCurve {
+	curve := new(BSplineCurve)
+	curve.points = points
+	return curve
+}
+
+func (c *BSplineCurve) SetClosed(closed bool) *BSplineCurve {
+	c.closed = closed
+	return c
+}
+
+func (c *BSplineCurve) Segments() int {
+	if c.closed {
+		return len(c.points)
+	}
+	return len(c.points) + 1
+}
+
+func (c *BSplineCurve) segment(t float32) ([4]mgl32.Vec3, float32, int) {
+	segments := c.Segments()
+	i, s := locateSegment(t, segments)
+	var p [4]mgl32.Vec3
+	for j := range p {
+		if c.closed {
+			p[j] = c.wrap(i + j - 1)
+		} else {
+			p[j] = c.points[clampInt(i+j-2, 0, len(c.points)-1)]
+		}
+	}
+	return p, s, segments
+}
+
+func (c *BSplineCurve) Point(t float32) mgl32.Vec3 {
+	if len(c.points) < 2 {
+		return deCasteljau(c.points, t)
+	}
+	p, s, _ := c.segment(t)
+	s2, s3 := s*s, s*s*s
+	r := 1 - s
+	return p[0].Mul(r * r * r).
+		Add(p[1].Mul(3*s3 - 6*s2 + 4)).
+		Add(p[2].Mul(-3*s3 + 3*s2 + 3*s + 1)).
+		Add(p[3].Mul(s3)).
+		Mul(1.0 / 6)
+}
+
+func (c *BSplineCurve) Tangent(t float32) mgl32.Vec3 {
+	if len(c.points) < 2 {
+		return mgl32.Vec3{}
+	}
+	p, s, segments := c.segment(t)
+	s2 := s * s
+	r := 1 - s
+	return p[0].Mul(-3 * r * r).
+		Add(p[1].Mul(9*s2 - 12*s)).
+		Add(p[2].Mul(-9*s2 + 6*s + 3)).
+		Add(p[3].Mul(3 * s2)).
+		Mul(float32(segments) / 6)
+}
+
+// Samples a curve to convert between t and the distance travelled along it,
+// so that it can be walked at constant speed
+type ArcLength struct {
+	curve     Curve
+	params    []float32
+	distances []float32
+}
+
+// Measures curve over the given number of equal steps in t. More samples
+// follow tight bends more closely.
+func NewArcLength(curve Curve, samples int) *ArcLength {
+	if samples < 1 {
+		samples = 1
+	}
+	a := new(ArcLength)
+	a.curve = curve
+	a.params = make([]float32, samples+1)
+	a.distances = make([]float32, samples+1)
+	previous := curve.Point(0)
+	for i := 1; i <= samples; i++ {
+		t := float32(i) / float32(samples)
+		p := curve.Point(t)
+		a.params[i] = t
+		a.distances[i] = a.distances[i-1] + p.Sub(previous).Len()
+		previous = p
+	}
+	return a
+}
+
+func (a *ArcLength) Curve() Curve {
+	return a.curve
+}
+
+func (a *ArcLength) Length() float32 {
+	return a.distances[len(a.distances)-1]
+}
+
+// Parameter of the point the given distance from the start, clamped to the
+// ends of the curve
+func (a *ArcLength) Parameter(distance float32) float32 {
+	i := sort.Search(len(a.distances), func(i int) bool {
+		return a.distances[i] >= distance
+	})
+	if i == 0 {
+		return 0
+	}
+	if i == len(a.distances) {
+		return 1
+	}
+	span := a.distances[i] - a.distances[i-1]
+	if span == 0 {
+		return a.params[i]
+	}
+	f := (distance - a.distances[i-1]) / span
+	return a.params[i-1] + f*(a.params[i]-a.params[i-1])
+}
+
+// Distance from the start to the point at t
+func (a *ArcLength) Distance(t float32) float32 {
+	i, s := locateSegment(t, len(a.params)-1)
+	return a.distances[i] + s*(a.distances[i+1]-a.distances[i])
+}
+
+func (a *ArcLength) Point(distance float32) mgl32.Vec3 {
+	return a.curve.Point(a.Parameter(distance))
+}
+
+// Unit tangent at the given distance, zero only for a curve that does not
+// move at all
+func (a *ArcLength) Direction(distance float32) mgl32.Vec3 {
+	return curveDirection(a.curve, a.Parameter(distance))
+}
+
+// Unit tangent at t. Where the derivative vanishes, as at the ends of open
+// B-splines, the direction towards a nearby point is used instead.
+func curveDirection(curve Curve, t float32) mgl32.Vec3 {
+	tangent := curve.Tangent(t)
+	if tangent.Len() == 0 {
+		const step = 1e-3
+		if t+step <= 1 {
+			tangent = curve.Point(t + step).Sub(curve.Point(t))
+		} else {
+			tangent = curve.Point(t).Sub(curve.Point(t - step))
+		}
+	}
+	if tangent.Len() == 0 {
+		return tangent
+	}
+	return tangent.Normalize()
+}
+
+// Position along the curve at speed as a function of time, e.g. for
+// Particle.SetKinematicPath. Looping paths start over at the end, others
+// stop there.
+func (a *ArcLength) Path(speed float32, loop bool) func(time float32) mgl32.Vec3 {
+	return func(time float32) mgl32.Vec3 {
+		return a.Point(a.travelled(time*speed, loop))
+	}
+}
+
+func (a *ArcLength) travelled(distance float32, loop bool) float32 {
+	length := a.Length()
+	if !loop || length == 0 {
+		return float32(math.Max(0, math.Min(float64(length), float64(distance))))
+	}
+	d := math.Mod(float64(distance), float64(length))
+	if d < 0 {
+		d += float64(length)
+	}
+	return float32(d)
+}
+
+// Parameters of a polyline that deviates from the curve by about tolerance
+// at most. Spans are halved while their midpoint is further than tolerance
+// from the chord.
+func curveParameters(curve Curve, tolerance float32) []float32 {
+	const maxDepth = 12
+	spans := 8
+	if s, ok := curve.(interface{ Segments() int }); ok && s.Segments() > spans {
+		spans = s.Segments()
+	}
+
+	var subdivide func(t0, t1 float32, p0, p1 mgl32.Vec3, depth int)
+	params := []float32{0}
+	subdivide = func(t0, t1 float32, p0, p1 mgl32.Vec3, depth int) {
+		tm := (t0 + t1) / 2
+		pm := curve.Point(tm)
+		if depth < maxDepth && pm.Sub(p0.Add(p1).Mul(0.5)).Len() > tolerance {
+			subdivide(t0, tm, p0, pm, depth+1)
+			subdivide(tm, t1, pm, p1, depth+1)
+			return
+		}
+		params = append(params, t1)
+	}
+	previous := curve.Point(0)
+	for i := 1; i <= spans; i++ {
+		t0, t1 := float32(i-1)/float32(spans), float32(i)/float32(spans)
+		p := curve.Point(t1)
+		subdivide(t0, t1, previous, p, 0)
+		previous = p
+	}
+	return params
+}
+
+// Line strip along the curve, finer where it bends. Texture u runs from 0
+// to 1 along the length.
+func CreateCurveGeometry(curve Curve, tolerance float32) *Geometry {
+	params := curveParameters(curve, tolerance)
+	points := make([]mgl32.Vec3, len(params))
+	for i, t := range params {
+		points[i] = curve.Point(t)
+	}
+	return CreateLineStripGeometry(points...)
+}
+
+// Flat band of the given width centred on the curve. up sets which way the
+// band faces at the start, from there its side is carried along the curve
+// without twisting. A curve in the xy plane with up +z gives a band facing
+// +z. Texture u runs across the band and v along it.
+func CreateRibbonGeometry(curve Curve, width, tolerance float32, up mgl32.Vec3) *Geometry {
+	params := curveParameters(curve, tolerance)
+	b := new(meshBuilder)
+	var side mgl32.Vec3
+	var distance float32
+	var previous mgl32.Vec3
+	var distances []float32
+	var frames [][3]mgl32.Vec3
+	for i, t := range params {
+		p := curve.Point(t)
+		if i > 0 {
+			distance += p.Sub(previous).Len()
+		}
+		previous = p
+
+		tangent := curveDirection(curve, t)
+		if i == 0 || side.Len() == 0 {
+			side = tangent.Cross(up)
+		}
+		// Keep the previous side, minus its part along the new tangent
+		side = side.Sub(tangent.Mul(side.Dot(tangent)))
+		if side.Len() > 0 {
+			side = side.Normalize()
+		}
+		frames = append(frames, [3]mgl32.Vec3{p, side, side.Cross(tangent)})
+		distances = append(distances, distance)
+	}
+
+	half := width / 2
+	for i, frame := range frames {
+		v := float32(0)
+		if distance > 0 {
+			v = distances[i] / distance
+		}
+		p, side, normal := frame[0], frame[1], frame[2]
+		b.vertex(p.Sub(side.Mul(half)), normal, mgl32.Vec2{0, v})
+		b.vertex(p.Add(side.Mul(half)), normal, mgl32.Vec2{1, v})
+	}
+	for i := 0; i+1 < len(frames); i++ {
+		left, right := uint32(i*2), uint32(i*2+1)
+		nextLeft, nextRight := left+2, right+2
+		b.triangle(right, nextRight, nextLeft)
+		b.triangle(right, nextLeft, left)
+	}
+	return b.geometry()
+}
+
+// Number of samples of the arc length tables of path followers
+const pathSamples = 256
+
+// Moves along a curve at constant speed, for driving objects and cameras
+// from the frame loop
+type PathFollower struct {
+	path     *ArcLength
+	speed    float32
+	loop     bool
+	distance float32
+}
+
+func NewPathFollower(curve Curve, speed float32) *PathFollower {
+	follower := new(PathFollower)
+	follower.path = NewArcLength(curve, pathSamples)
+	follower.speed = speed
+	return follower
+}
+
+func (f *PathFollower) SetSpeed(speed float32) *PathFollower {
+	f.speed = speed
+	return f
+}
+
+func (f *PathFollower) Speed() float32 {
+	return f.speed
+}
+
+// Looping followers start over at the start when passing the end, others
+// stop at the end
+func (f *PathFollower) SetLoop(loop bool) *PathFollower {
+	f.loop = loop
+	return f
+}
+
+func (f *PathFollower) SetDistance(distance float32) *PathFollower {
+	f.distance = f.path.travelled(distance, f.loop)
+	return f
+}
+
+// Distance travelled from the start
+func (f *PathFollower) Distance() float32 {
+	return f.distance
+}
+
+func (f *PathFollower) Path() *ArcLength {
+	return f.path
+}
+
+func (f *PathFollower) Update(time_delta float32) *PathFollower {
+	return f.SetDistance(f.distance + f.speed*time_delta)
+}
+
+// True once a follower that does not loop has reached the end in its
+// direction of travel
+func (f *PathFollower) Done() bool {
+	if f.loop {
+		return false
+	}
+	if f.speed < 0 {
+		return f.distance <= 0
+	}
+	return f.distance >= f.path.Length()
+}
+
+func (f *PathFollower) Position() mgl32.Vec3 {
+	return f.path.Point(f.distance)
+}
+
+// Unit direction of travel
+func (f *PathFollower) Direction() mgl32.Vec3 {
+	direction := f.path.Direction(f.distance)
+	if f.speed < 0 {
+		return direction.Mul(-1)
+	}
+	return direction
+}
+
+// Moves the object to the current position and turns its +y axis, the axis
+// of arrows and cylinders, in the direction of travel
+func (f *PathFollower) Place(o *Object) *PathFollower {
+	p := f.Position()
+	o.SetPosition(p[0], p[1], p[2])
+	if direction := f.Direction(); direction.Len() > 0 {
+		o.SetRotation(mgl32.QuatBetweenVectors(mgl32.Vec3{0, 1, 0}, direction))
+	}
+	return f
+}
+
+// Puts the camera at the current position looking in the direction of
+// travel
+func (f *PathFollower) Aim(c *Camera, up mgl32.Vec3) *PathFollower {
+	p := f.Position()
+	if direction := f.Direction(); direction.Len() > 0 {
+		c.LookAt(p, p.Add(direction), up)
+	}
+	return f
+}
@@ -0,0 +1,57 @@
+package go_world
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"math/rand"
+)
+
+// Spawns Rate particles per second at Position. Every particle gets Velocity
+// plus a random offset of up to Spread in each direction.
+type Emitter struct {
+	Position mgl32.Vec3
+	Velocity mgl32.Vec3
+	Spread   float32
+	Rate     float32
+	Radius   float32
+	Mass     float32
+	// Stops emitting after MaxParticles, 0 emits forever
+	MaxParticles int
+
+	emitted int
+	pending float32
+}
+
+func NewEmitter(position mgl32.Vec3, rate float32) *Emitter {
+	emitter := new(Emitter)
+	emitter.Position = position
+	emitter.Rate = rate
+	emitter.Radius = 1
+	emitter.Mass = 1
+	return emitter
+}
+
+func (e *Emitter) Emitted() int {
+	return e.emitted
+}
+
+func (e *Emitter) emit(ps *ParticleSystem, time_delta float32) {
+	e.pending += e.Rate * time_delta
+	for e.pending >= 1 {
+		e.pending--
+		if e.MaxParticles > 0 && e.emitted >= e.MaxParticles {
+			e.pending = 0
+			return
+		}
+		velocity := e.Velocity.Add(mgl32.Vec3{
+			e.Spread * (2*rand.Float32() - 1),
+			e.Spread * (2*rand.Float32() - 1),
+			e.Spread * (2*rand.Float32() - 1),
+		})
+		ps.NewParticle().
+			SetRadius(e.Radius).
+			SetMass(e.Mass).
+			SetPosition(e.Position[0], e.Position[1], e.Position[2]).
+			SetVelocity(velocity[0], velocity[1], velocity[2])
+		e.emitted++
+	}
+}
@@ -0,0 +1,92 @@
+package go_world
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// In place radix-2 FFT, len(data) has to be a power of two. The inverse
+// transform is not normalized.
+func fft(data []complex128, inverse bool) {
+	n := len(data)
+	if n < 2 {
+		return
+	}
+
+	// Bit reversal permutation
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j |= bit
+		if i < j {
+			data[i], data[j] = data[j], data[i]
+		}
+	}
+
+	sign := -1.0
+	if inverse {
+		sign = 1.0
+	}
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Rect(1, sign*2*math.Pi/float64(size))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				even := data[start+k]
+				odd := data[start+k+size/2] * w
+				data[start+k] = even + odd
+				data[start+k+size/2] = even - odd
+				w *= step
+			}
+		}
+	}
+}
+
+// FFT along every axis of a row major 3D grid with the given dimensions.
+// The inverse transform is normalized.
+func fft3D(data []complex128, dims [3]int, inverse bool) {
+	line := make([]complex128, 0, maxInt(dims[0], maxInt(dims[1], dims[2])))
+	strides := [3]int{dims[1] * dims[2], dims[2], 1}
+
+	for axis := 0; axis < 3; axis++ {
+		n := dims[axis]
+		if n < 2 {
+			continue
+		}
+		stride := strides[axis]
+		for start := 0; start < len(data); start++ {
+			// Only lines starting at index 0 of this axis
+			if (start/stride)%n != 0 {
+				continue
+			}
+			line = line[:0]
+			for i := 0; i < n; i++ {
+				line = append(line, data[start+i*stride])
+			}
+			fft(line, inverse)
+			for i := 0; i < n; i++ {
+				data[start+i*stride] = line[i]
+			}
+		}
+	}
+
+	if inverse {
+		scale := complex(1/float64(len(data)), 0)
+		for i := range data {
+			data[i] *= scale
+		}
+	}
+}
+
+func isPowerOfTwo(n int) bool {
+	return n > 0 && n&(n-1) == 0
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,60 @@
+package go_world
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Same force on every particle, e.g. wind
+type UniformForceField struct {
+	Force mgl32.Vec3
+}
+
+func NewUniformForceField(force mgl32.Vec3) *UniformForceField {
+	return &UniformForceField{Force: force}
+}
+
+func (ff *UniformForceField) Apply(p *Particle, time_delta float32) {
+	dv := ff.Force.Mul(time_delta / p.Mass())
+	p.ApplyForce(dv[0], dv[1], dv[2])
+}
+
+// Inverse square attraction towards Center, negative strength repels.
+type PointForceField struct {
+	Center   mgl32.Vec3
+	Strength float32
+	// Distances below MinDistance are clamped to avoid singular forces
+	MinDistance float32
+}
+
+func NewPointForceField(center mgl32.Vec3, strength float32) *PointForceField {
+	return &PointForceField{Center: center, Strength: strength, MinDistance: 0.01}
+}
+
+func (ff *PointForceField) Apply(p *Particle, time_delta float32) {
+	offset := ff.Center.Sub(p.Position())
+	distance := offset.Len()
+	if distance == 0 {
+		return
+	}
+	clamped := distance
+	if clamped < ff.MinDistance {
+		clamped = ff.MinDistance
+	}
+	magnitude := ff.Strength / (clamped * clamped)
+	dv := offset.Mul(magnitude * time_delta / (distance * p.Mass()))
+	p.ApplyForce(dv[0], dv[1], dv[2])
+}
+
+// Linear drag opposing the velocity
+type DragForceField struct {
+	Coefficient float32
+}
+
+func NewDragForceField(coefficient float32) *DragForceField {
+	return &DragForceField{Coefficient: coefficient}
+}
+
+func (ff *DragForceField) Apply(p *Particle, time_delta float32) {
+	dv := p.Velocity().Mul(-ff.Coefficient * time_delta / p.Mass())
+	p.ApplyForce(dv[0], dv[1], dv[2])
+}
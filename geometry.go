@@ -4,19 +4,79 @@ import (
 	"github.com/go-gl/gl/v4.1-core/gl"
 	"github.com/go-gl/mathgl/mgl32"
 	"math"
+	"unsafe"
 )
 
 type Geometry struct {
 	vertices    []float32
+	normals     []float32
+	uvs         []float32
+	colors      []float32
+	custom      []customAttribute
+	indices     []uint32
 	draw_method uint32
+
+	bounds      AABB
+	sphere      BoundingSphere
+	boundsValid bool
+}
+
+func (g *Geometry) vertexCount() int32 {
+	return int32(len(g.vertices) / 3)
+}
+
+func (g *Geometry) indexCount() int32 {
+	return int32(len(g.indices))
+}
+
+// Draws the vertices in the given order instead of one after another, so
+// vertices shared by several primitives are stored once. nil or no indices
+// draw the vertices directly again.
+func (g *Geometry) SetIndices(indices ...uint32) *Geometry {
+	g.indices = indices
+	return g
+}
+
+func (g *Geometry) Indices() []uint32 {
+	return g.indices
+}
+
+func (g *Geometry) indexed() bool {
+	return len(g.indices) > 0
 }
 
+// Smallest element type able to address all vertices, with the indices in
+// that type for uploading
+func (g *Geometry) elementData() (uint32, int, unsafe.Pointer) {
+	if g.vertexCount() <= math.MaxUint16+1 {
+		short := make([]uint16, len(g.indices))
+		for i, index := range g.indices {
+			short[i] = uint16(index)
+		}
+		return gl.UNSIGNED_SHORT, len(short) * 2, gl.Ptr(short)
+	}
+	return gl.UNSIGNED_INT, len(g.indices) * 4, gl.Ptr(g.indices)
+}
+
+// Sets one RGBA colour per vertex
+func (g *Geometry) SetColors(colors ...mgl32.Vec4) *Geometry {
+	g.colors = g.colors[:0]
+	for _, c := range colors {
+		g.colors = append(g.colors, c[0], c[1], c[2], c[3])
+	}
+	return g
+}
+
+// UV sphere of indexed triangles. Vertices of the first and last sector
+// coincide along a seam.
 func createSphereGeometry(radius float32, rings, sectors float64) *Geometry {
 	var R float64 = float64(1.0 / (rings - 1))
 	var S float64 = float64(1.0 / (sectors - 1))
 
 	num_vertices := uint32(rings * sectors * 3)
 	vertices := make([]float32, num_vertices)
+	normals := make([]float32, num_vertices)
+	uvs := make([]float32, 0, int(rings*sectors)*2)
 
 	var r, s float64
 	var x, y, z float64
@@ -28,17 +88,41 @@ func createSphereGeometry(radius float32, rings, sectors float64) *Geometry {
 			x = math.Cos(2.0*math.Pi*s*S) * math.Sin(math.Pi*r*R)
 			z = math.Sin(2.0*math.Pi*s*S) * math.Sin(math.Pi*r*R)
 
-			vertices[i] = float32(x)
+			normals[i], normals[i+1], normals[i+2] = float32(x), float32(y), float32(z)
+			uvs = append(uvs, float32(s*S), float32(r*R))
+
+			vertices[i] = float32(x) * radius
 			i += 1
-			vertices[i] = float32(y)
+			vertices[i] = float32(y) * radius
 			i += 1
-			vertices[i] = float32(z)
+			vertices[i] = float32(z) * radius
 			i += 1
 		}
 	}
 
+	rows, columns := int(rings), int(sectors)
+	indices := make([]uint32, 0, (rows-1)*(columns-1)*6)
+	for r := 0; r < rows-1; r++ {
+		for s := 0; s < columns-1; s++ {
+			current := uint32(r*columns + s)
+			above := current + uint32(columns)
+			// The rows at the poles collapse to a point, leaving one
+			// triangle per quad
+			if r > 0 {
+				indices = append(indices, current, above, current+1)
+			}
+			if r < rows-2 {
+				indices = append(indices, current+1, above, above+1)
+			}
+		}
+	}
+
 	geometry := new(Geometry)
 	geometry.vertices = vertices
+	geometry.normals = normals
+	geometry.uvs = uvs
+	geometry.indices = indices
+	geometry.draw_method = gl.TRIANGLES
 	return geometry
 }
 
@@ -58,6 +142,8 @@ func createDiamondGeometry(side_length float32) *Geometry {
 	}
 	geometry := new(Geometry)
 	geometry.vertices = vertices
+	geometry.normals = constantNormals(len(vertices)/3, mgl32.Vec3{0, 0, 1})
+	geometry.uvs = planarUVs(vertices)
 	return geometry
 }
 
@@ -71,6 +157,8 @@ func createTriangle2DGeometry(side_length float32) *Geometry {
 	}
 	geometry := new(Geometry)
 	geometry.vertices = vertices
+	geometry.normals = constantNormals(len(vertices)/3, mgl32.Vec3{0, 0, 1})
+	geometry.uvs = planarUVs(vertices)
 	return geometry
 }
 
@@ -82,6 +170,8 @@ func CreateLineGeometry(start, end mgl32.Vec3) *Geometry {
 
 	geometry := new(Geometry)
 	geometry.vertices = vertices
+	geometry.normals = constantNormals(2, mgl32.Vec3{0, 0, 1})
+	geometry.uvs = lineUVs(vertices)
 	geometry.draw_method = gl.LINES
 	return geometry
 }
@@ -94,11 +184,23 @@ func CreateLineLoopGeometry(points ...mgl32.Vec3) *Geometry {
 	}
 	geometry := new(Geometry)
 	geometry.vertices = vertices
+	geometry.normals = constantNormals(len(vertices)/3, mgl32.Vec3{0, 0, 1})
+	geometry.uvs = lineUVs(vertices)
 	geometry.draw_method = gl.LINES
 
 	return geometry
 }
 
+func CreateLineStripGeometry(points ...mgl32.Vec3) *Geometry {
+	geometry := new(Geometry)
+	geometry.vertices = to_array(points...)
+	geometry.normals = constantNormals(len(points), mgl32.Vec3{0, 0, 1})
+	geometry.uvs = lineUVs(geometry.vertices)
+	geometry.draw_method = gl.LINE_STRIP
+
+	return geometry
+}
+
 func createCubeGeometry(side_length float32) *Geometry {
 	side_length = side_length
 	var vertices = []float32{
@@ -152,6 +254,9 @@ func createCubeGeometry(side_length float32) *Geometry {
 	}
 	geometry := new(Geometry)
 	geometry.vertices = vertices
+	geometry.normals = flatNormals(vertices)
+	geometry.uvs = boxUVs(vertices, geometry.normals)
+	geometry.draw_method = gl.TRIANGLES
 	return geometry
 }
 
@@ -187,6 +292,8 @@ func createCircleGeometry(num_vertices int, radius float32) *Geometry {
 
 	geometry := new(Geometry)
 	geometry.vertices = vertices
+	geometry.normals = constantNormals(len(vertices)/3, mgl32.Vec3{0, 0, 1})
+	geometry.uvs = planarUVs(vertices)
 	geometry.draw_method = gl.TRIANGLES
 	return geometry
 }
@@ -208,13 +315,16 @@ func createOctahedronGeometry() *Geometry {
 	t2 := Triangle{p2, p3, p0}
 	t3 := Triangle{p3, p4, p0}
 	t4 := Triangle{p4, p1, p0}
-	t5 := Triangle{p5, p1, p2}
-	t6 := Triangle{p5, p2, p3}
-	t7 := Triangle{p5, p3, p4}
-	t8 := Triangle{p5, p4, p1}
+	t5 := Triangle{p5, p2, p1}
+	t6 := Triangle{p5, p3, p2}
+	t7 := Triangle{p5, p4, p3}
+	t8 := Triangle{p5, p1, p4}
 
 	geometry := new(Geometry)
 	geometry.vertices = t_to_array(t1, t2, t3, t4, t5, t6, t7, t8)
+	geometry.normals = flatNormals(geometry.vertices)
+	geometry.uvs = boxUVs(geometry.vertices, geometry.normals)
+	geometry.draw_method = gl.TRIANGLES
 	return geometry
 }
 
@@ -226,15 +336,47 @@ func to_array(vertices ...mgl32.Vec3) []float32 {
 	return array
 }
 
+// Corners counter clockwise seen from the front
 type Triangle struct {
-	v1, v2, v3 mgl32.Vec3
+	V1, V2, V3 mgl32.Vec3
+}
+
+// Unit normal of the front face, zero for degenerate triangles
+func (t Triangle) Normal() mgl32.Vec3 {
+	n := t.V2.Sub(t.V1).Cross(t.V3.Sub(t.V1))
+	if n.Len() == 0 {
+		return n
+	}
+	return n.Normalize()
+}
+
+func (t Triangle) Area() float32 {
+	return t.V2.Sub(t.V1).Cross(t.V3.Sub(t.V1)).Len() / 2
 }
 
 func t_to_array(triangles ...Triangle) []float32 {
 	var array []float32
 	for _, t := range triangles {
-		values := to_array(t.v1, t.v2, t.v3)
+		values := to_array(t.V1, t.V2, t.V3)
 		array = append(array, values...)
 	}
 	return array
 }
+
+// Triangles of a triangle list, indexed or not
+func (g *Geometry) Triangles() []Triangle {
+	vertex := func(i uint32) mgl32.Vec3 {
+		return mgl32.Vec3{g.vertices[i*3], g.vertices[i*3+1], g.vertices[i*3+2]}
+	}
+	var triangles []Triangle
+	if g.indexed() {
+		for i := 0; i+2 < len(g.indices); i += 3 {
+			triangles = append(triangles, Triangle{vertex(g.indices[i]), vertex(g.indices[i+1]), vertex(g.indices[i+2])})
+		}
+		return triangles
+	}
+	for i := uint32(0); i+2 < uint32(g.vertexCount()); i += 3 {
+		triangles = append(triangles, Triangle{vertex(i), vertex(i + 1), vertex(i + 2)})
+	}
+	return triangles
+}
@@ -0,0 +1,542 @@
+package go_world
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-gl/mathgl/mgl32"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"strings"
+)
+
+// Subset of the glTF 2.0 JSON needed for static meshes
+type gltfDocument struct {
+	Scene  *int `json:"scene"`
+	Scenes []struct {
+		Nodes []int `json:"nodes"`
+	} `json:"scenes"`
+	Nodes []struct {
+		Name        string    `json:"name"`
+		Mesh        *int      `json:"mesh"`
+		Children    []int     `json:"children"`
+		Matrix      []float32 `json:"matrix"`
+		Translation []float32 `json:"translation"`
+		Rotation    []float32 `json:"rotation"`
+		Scale       []float32 `json:"scale"`
+	} `json:"nodes"`
+	Meshes []struct {
+		Name       string          `json:"name"`
+		Primitives []gltfPrimitive `json:"primitives"`
+	} `json:"meshes"`
+	Materials []struct {
+		Name string `json:"name"`
+		PBR  *struct {
+			BaseColorFactor []float32 `json:"baseColorFactor"`
+		} `json:"pbrMetallicRoughness"`
+	} `json:"materials"`
+	Accessors   []gltfAccessor `json:"accessors"`
+	BufferViews []struct {
+		Buffer     int `json:"buffer"`
+		ByteOffset int `json:"byteOffset"`
+		ByteLength int `json:"byteLength"`
+		ByteStride int `json:"byteStride"`
+	} `json:"bufferViews"`
+	Buffers []struct {
+		URI        string `json:"uri"`
+		ByteLength int    `json:"byteLength"`
+	} `json:"buffers"`
+}
+
+type gltfPrimitive struct {
+	Attributes map[string]int `json:"attributes"`
+	Indices    *int           `json:"indices"`
+	Material   *int           `json:"material"`
+	Mode       *uint32        `json:"mode"`
+}
+
+type gltfAccessor struct {
+	BufferView    *int            `json:"bufferView"`
+	ByteOffset    int             `json:"byteOffset"`
+	ComponentType int             `json:"componentType"`
+	Normalized    bool            `json:"normalized"`
+	Count         int             `json:"count"`
+	Type          string          `json:"type"`
+	Sparse        json.RawMessage `json:"sparse"`
+}
+
+const (
+	glbMagic     = 0x46546C67
+	glbChunkJSON = 0x4E4F534A
+	glbChunkBIN  = 0x004E4942
+)
+
+var gltfComponents = map[string]int{"SCALAR": 1, "VEC2": 2, "VEC3": 3, "VEC4": 4, "MAT2": 4, "MAT3": 9, "MAT4": 16}
+
+// Imports the default scene of a .gltf or .glb file into scene, external
+// buffers are looked up relative to the file. Returns the objects of the
+// root nodes, moving them moves the whole model.
+func ImportGLTFFile(path string, scene *Scene) ([]*Object, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(path)
+	roots, err := ImportGLTF(data, func(uri string) ([]byte, error) {
+		return ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(uri)))
+	}, scene)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return roots, nil
+}
+
+// Imports glTF JSON or a binary .glb container. Every node becomes an
+// Object carrying its transform with its parent node as parent, each
+// primitive of its mesh a child Object in scene. Vertices are coloured with
+// the base colour of the material. openBuffer loads buffers referenced by
+// URI, data URIs are decoded directly.
+func ImportGLTF(data []byte, openBuffer func(uri string) ([]byte, error), scene *Scene) ([]*Object, error) {
+	var binChunk []byte
+	if len(data) >= 12 && binary.LittleEndian.Uint32(data) == glbMagic {
+		var err error
+		if data, binChunk, err = parseGLB(data); err != nil {
+			return nil, err
+		}
+	}
+
+	var doc gltfDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid glTF JSON: %v", err)
+	}
+
+	importer := &gltfImporter{doc: &doc, scene: scene}
+	for i, buffer := range doc.Buffers {
+		var content []byte
+		var err error
+		switch {
+		case buffer.URI == "" && i == 0 && binChunk != nil:
+			content = binChunk
+		case strings.HasPrefix(buffer.URI, "data:"):
+			content, err = decodeDataURI(buffer.URI)
+		case buffer.URI == "":
+			err = errors.New("missing uri")
+		case openBuffer == nil:
+			err = errors.New("external buffers can not be opened")
+		default:
+			content, err = openBuffer(buffer.URI)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("buffer %d: %v", i, err)
+		}
+		if len(content) < buffer.ByteLength {
+			return nil, fmt.Errorf("buffer %d: %d bytes, expected %d", i, len(content), buffer.ByteLength)
+		}
+		importer.buffers = append(importer.buffers, content)
+	}
+
+	var roots []int
+	switch {
+	case doc.Scene != nil && *doc.Scene < len(doc.Scenes):
+		roots = doc.Scenes[*doc.Scene].Nodes
+	case doc.Scene != nil:
+		return nil, fmt.Errorf("scene %d out of range", *doc.Scene)
+	case len(doc.Scenes) > 0:
+		roots = doc.Scenes[0].Nodes
+	}
+
+	var objects []*Object
+	for _, node := range roots {
+		object, err := importer.node(node, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, object)
+	}
+	return objects, nil
+}
+
+// Splits a .glb container into its JSON and binary chunks
+func parseGLB(data []byte) ([]byte, []byte, error) {
+	version := binary.LittleEndian.Uint32(data[4:])
+	length := binary.LittleEndian.Uint32(data[8:])
+	if version != 2 {
+		return nil, nil, fmt.Errorf("unsupported glb version %d", version)
+	}
+	if int(length) > len(data) {
+		return nil, nil, fmt.Errorf("glb truncated, %d of %d bytes", len(data), length)
+	}
+
+	var jsonChunk, binChunk []byte
+	for offset := 12; offset+8 <= int(length); {
+		chunkLength := int(binary.LittleEndian.Uint32(data[offset:]))
+		chunkType := binary.LittleEndian.Uint32(data[offset+4:])
+		start := offset + 8
+		if start+chunkLength > int(length) {
+			return nil, nil, errors.New("glb chunk exceeds file")
+		}
+		switch chunkType {
+		case glbChunkJSON:
+			jsonChunk = data[start : start+chunkLength]
+		case glbChunkBIN:
+			if binChunk == nil {
+				binChunk = data[start : start+chunkLength]
+			}
+		}
+		offset = start + chunkLength
+	}
+	if jsonChunk == nil {
+		return nil, nil, errors.New("glb without JSON chunk")
+	}
+	return jsonChunk, binChunk, nil
+}
+
+func decodeDataURI(uri string) ([]byte, error) {
+	comma := strings.IndexByte(uri, ',')
+	if comma < 0 || !strings.HasSuffix(uri[:comma], ";base64") {
+		return nil, errors.New("only base64 data URIs are supported")
+	}
+	return base64.StdEncoding.DecodeString(uri[comma+1:])
+}
+
+type gltfImporter struct {
+	doc     *gltfDocument
+	buffers [][]byte
+	scene   *Scene
+}
+
+func (gi *gltfImporter) node(index int, parent *Object, depth int) (*Object, error) {
+	if index < 0 || index >= len(gi.doc.Nodes) {
+		return nil, fmt.Errorf("node %d out of range", index)
+	}
+	if depth > len(gi.doc.Nodes) {
+		return nil, errors.New("node hierarchy contains a cycle")
+	}
+	node := gi.doc.Nodes[index]
+
+	object := NewObject(nil).SetParent(parent)
+	switch {
+	case len(node.Matrix) == 16:
+		var m mgl32.Mat4
+		copy(m[:], node.Matrix)
+		translation, rotation, scale := decomposeMatrix(m)
+		object.SetPosition(translation[0], translation[1], translation[2])
+		object.SetRotation(rotation)
+		object.SetScaleXYZ(scale[0], scale[1], scale[2])
+	case len(node.Matrix) != 0:
+		return nil, fmt.Errorf("node %d: matrix needs 16 values", index)
+	default:
+		if len(node.Translation) == 3 {
+			object.SetPosition(node.Translation[0], node.Translation[1], node.Translation[2])
+		}
+		if len(node.Rotation) == 4 {
+			// glTF stores x, y, z, w
+			r := node.Rotation
+			object.SetRotation(mgl32.Quat{W: r[3], V: mgl32.Vec3{r[0], r[1], r[2]}}.Normalize())
+		}
+		if len(node.Scale) == 3 {
+			object.SetScaleXYZ(node.Scale[0], node.Scale[1], node.Scale[2])
+		}
+	}
+
+	if node.Mesh != nil {
+		if *node.Mesh < 0 || *node.Mesh >= len(gi.doc.Meshes) {
+			return nil, fmt.Errorf("node %d: mesh %d out of range", index, *node.Mesh)
+		}
+		for i, primitive := range gi.doc.Meshes[*node.Mesh].Primitives {
+			geometry, err := gi.primitive(primitive)
+			if err != nil {
+				return nil, fmt.Errorf("mesh %d primitive %d: %v", *node.Mesh, i, err)
+			}
+			child := NewObject(geometry).SetParent(object)
+			child.configure(gi.scene.program)
+			gi.scene.AddObject(child)
+		}
+	}
+
+	for _, child := range node.Children {
+		if _, err := gi.node(child, object, depth+1); err != nil {
+			return nil, err
+		}
+	}
+	return object, nil
+}
+
+func (gi *gltfImporter) primitive(primitive gltfPrimitive) (*Geometry, error) {
+	position, ok := primitive.Attributes["POSITION"]
+	if !ok {
+		return nil, errors.New("missing POSITION")
+	}
+	geometry := new(Geometry)
+	var err error
+	if geometry.vertices, err = gi.floats(position, "VEC3"); err != nil {
+		return nil, fmt.Errorf("POSITION: %v", err)
+	}
+	count := int(geometry.vertexCount())
+
+	// Triangles, the default mode, and the other modes share the values
+	// of the OpenGL constants
+	geometry.draw_method = 4
+	if primitive.Mode != nil {
+		if *primitive.Mode > 6 {
+			return nil, fmt.Errorf("invalid mode %d", *primitive.Mode)
+		}
+		geometry.draw_method = *primitive.Mode
+	}
+
+	if primitive.Indices != nil {
+		if geometry.indices, err = gi.indices(*primitive.Indices, count); err != nil {
+			return nil, fmt.Errorf("indices: %v", err)
+		}
+	}
+
+	if accessor, ok := primitive.Attributes["NORMAL"]; ok {
+		if geometry.normals, err = gi.floats(accessor, "VEC3"); err != nil {
+			return nil, fmt.Errorf("NORMAL: %v", err)
+		}
+	} else {
+		geometry.ComputeSmoothNormals()
+	}
+	if accessor, ok := primitive.Attributes["TEXCOORD_0"]; ok {
+		if geometry.uvs, err = gi.floats(accessor, "VEC2"); err != nil {
+			return nil, fmt.Errorf("TEXCOORD_0: %v", err)
+		}
+	}
+
+	base := mgl32.Vec4{1, 1, 1, 1}
+	if primitive.Material != nil {
+		if *primitive.Material < 0 || *primitive.Material >= len(gi.doc.Materials) {
+			return nil, fmt.Errorf("material %d out of range", *primitive.Material)
+		}
+		if pbr := gi.doc.Materials[*primitive.Material].PBR; pbr != nil && len(pbr.BaseColorFactor) == 4 {
+			copy(base[:], pbr.BaseColorFactor)
+		}
+	}
+	colors := make([]mgl32.Vec4, count)
+	for i := range colors {
+		colors[i] = base
+	}
+	if accessor, ok := primitive.Attributes["COLOR_0"]; ok {
+		values, size, err := gi.read(accessor)
+		if err != nil {
+			return nil, fmt.Errorf("COLOR_0: %v", err)
+		}
+		if (size != 3 && size != 4) || len(values) != count*size {
+			return nil, errors.New("COLOR_0 needs a VEC3 or VEC4 per vertex")
+		}
+		for i := range colors {
+			c := mgl32.Vec4{1, 1, 1, 1}
+			copy(c[:size], values[i*size:(i+1)*size])
+			colors[i] = mgl32.Vec4{c[0] * base[0], c[1] * base[1], c[2] * base[2], c[3] * base[3]}
+		}
+	}
+	geometry.SetColors(colors...)
+
+	if geometry.normals != nil && len(geometry.normals) != count*3 {
+		return nil, errors.New("NORMAL count differs from POSITION")
+	}
+	if geometry.uvs != nil && len(geometry.uvs) != count*2 {
+		return nil, errors.New("TEXCOORD_0 count differs from POSITION")
+	}
+	return geometry, nil
+}
+
+// Reads an accessor that has to be of the given type
+func (gi *gltfImporter) floats(index int, accessorType string) ([]float32, error) {
+	if index >= 0 && index < len(gi.doc.Accessors) && gi.doc.Accessors[index].Type != accessorType {
+		return nil, fmt.Errorf("accessor %d is %s, expected %s", index, gi.doc.Accessors[index].Type, accessorType)
+	}
+	values, _, err := gi.read(index)
+	return values, err
+}
+
+func (gi *gltfImporter) indices(index, vertexCount int) ([]uint32, error) {
+	if index < 0 || index >= len(gi.doc.Accessors) {
+		return nil, fmt.Errorf("accessor %d out of range", index)
+	}
+	accessor := gi.doc.Accessors[index]
+	switch accessor.ComponentType {
+	case 5121, 5123, 5125:
+	default:
+		return nil, errors.New("indices need unsigned integer components")
+	}
+	if accessor.Type != "SCALAR" {
+		return nil, errors.New("indices need SCALAR type")
+	}
+	elements, err := gi.elements(accessor)
+	if err != nil {
+		return nil, err
+	}
+	// Read as integers, float32 can not hold indices above 2^24
+	indices := make([]uint32, elements.count)
+	for i := range indices {
+		var value uint32
+		if elements.data != nil {
+			element := elements.at(i)
+			switch accessor.ComponentType {
+			case 5121:
+				value = uint32(element[0])
+			case 5123:
+				value = uint32(binary.LittleEndian.Uint16(element))
+			default:
+				value = binary.LittleEndian.Uint32(element)
+			}
+		}
+		if uint64(value) >= uint64(vertexCount) {
+			return nil, fmt.Errorf("index %d out of range (%d vertices)", value, vertexCount)
+		}
+		indices[i] = value
+	}
+	return indices, nil
+}
+
+// Values of an accessor as float32 and the number of components per element
+func (gi *gltfImporter) read(index int) ([]float32, int, error) {
+	if index < 0 || index >= len(gi.doc.Accessors) {
+		return nil, 0, fmt.Errorf("accessor %d out of range", index)
+	}
+	values, size, err := gi.readAccessor(gi.doc.Accessors[index])
+	if err != nil {
+		return nil, 0, fmt.Errorf("accessor %d: %v", index, err)
+	}
+	return values, size, nil
+}
+
+func (gi *gltfImporter) readAccessor(accessor gltfAccessor) ([]float32, int, error) {
+	elements, err := gi.elements(accessor)
+	if err != nil {
+		return nil, 0, err
+	}
+	size := elements.size
+	values := make([]float32, elements.count*size)
+	if elements.data == nil {
+		// Accessors without data are zero
+		return values, size, nil
+	}
+	for i := 0; i < elements.count; i++ {
+		element := elements.at(i)
+		for c := 0; c < size; c++ {
+			values[i*size+c] = gltfComponent(element[c*elements.componentSize:], accessor.ComponentType, accessor.Normalized)
+		}
+	}
+	return values, size, nil
+}
+
+// Upper limit of the elements of an accessor, which also bounds accessors
+// without data that are not backed by the file size
+const gltfMaxCount = 1 << 26
+
+// Elements of an accessor checked to lie within its buffer view
+type gltfElements struct {
+	data          []byte // nil for accessors without buffer view
+	offset        int
+	stride        int
+	count         int
+	size          int
+	componentSize int
+}
+
+func (e gltfElements) at(i int) []byte {
+	start := e.offset + i*e.stride
+	return e.data[start : start+e.size*e.componentSize]
+}
+
+func (gi *gltfImporter) elements(accessor gltfAccessor) (gltfElements, error) {
+	var elements gltfElements
+	var ok bool
+	if elements.size, ok = gltfComponents[accessor.Type]; !ok {
+		return elements, fmt.Errorf("unknown type %q", accessor.Type)
+	}
+	if len(accessor.Sparse) > 0 {
+		return elements, errors.New("sparse accessors are not supported")
+	}
+	elements.componentSize = map[int]int{5120: 1, 5121: 1, 5122: 2, 5123: 2, 5125: 4, 5126: 4}[accessor.ComponentType]
+	if elements.componentSize == 0 {
+		return elements, fmt.Errorf("unknown component type %d", accessor.ComponentType)
+	}
+	if accessor.Count < 0 || accessor.Count > gltfMaxCount {
+		return elements, fmt.Errorf("invalid count %d", accessor.Count)
+	}
+	if accessor.ByteOffset < 0 {
+		return elements, fmt.Errorf("invalid byte offset %d", accessor.ByteOffset)
+	}
+	elements.count = accessor.Count
+	if accessor.BufferView == nil {
+		return elements, nil
+	}
+
+	if *accessor.BufferView < 0 || *accessor.BufferView >= len(gi.doc.BufferViews) {
+		return elements, fmt.Errorf("buffer view %d out of range", *accessor.BufferView)
+	}
+	view := gi.doc.BufferViews[*accessor.BufferView]
+	if view.Buffer < 0 || view.Buffer >= len(gi.buffers) {
+		return elements, fmt.Errorf("buffer %d out of range", view.Buffer)
+	}
+	if view.ByteOffset < 0 || view.ByteLength < 0 || view.ByteStride < 0 {
+		return elements, errors.New("negative buffer view offset, length or stride")
+	}
+	buffer := gi.buffers[view.Buffer]
+	if view.ByteOffset > len(buffer) || view.ByteLength > len(buffer)-view.ByteOffset {
+		return elements, errors.New("buffer view exceeds buffer")
+	}
+	elements.data = buffer[view.ByteOffset : view.ByteOffset+view.ByteLength]
+
+	elementSize := elements.size * elements.componentSize
+	elements.offset = accessor.ByteOffset
+	elements.stride = view.ByteStride
+	if elements.stride == 0 {
+		elements.stride = elementSize
+	}
+	// Written to stay clear of overflow for huge offsets and counts
+	if elements.count > 0 {
+		room := len(elements.data) - elementSize
+		if elements.offset > room || elements.count-1 > (room-elements.offset)/elements.stride {
+			return elements, errors.New("accessor exceeds buffer view")
+		}
+	}
+	return elements, nil
+}
+
+func gltfComponent(data []byte, componentType int, normalized bool) float32 {
+	var value, max float32
+	switch componentType {
+	case 5120:
+		value, max = float32(int8(data[0])), 127
+	case 5121:
+		value, max = float32(data[0]), 255
+	case 5122:
+		value, max = float32(int16(binary.LittleEndian.Uint16(data))), 32767
+	case 5123:
+		value, max = float32(binary.LittleEndian.Uint16(data)), 65535
+	case 5125:
+		value, max = float32(binary.LittleEndian.Uint32(data)), math.MaxUint32
+	default:
+		return math.Float32frombits(binary.LittleEndian.Uint32(data))
+	}
+	if normalized {
+		return mgl32.Clamp(value/max, -1, 1)
+	}
+	return value
+}
+
+// Splits an affine transform without shear into translation, rotation and
+// scale
+func decomposeMatrix(m mgl32.Mat4) (mgl32.Vec3, mgl32.Quat, mgl32.Vec3) {
+	translation := m.Col(3).Vec3()
+	scale := mgl32.Vec3{m.Col(0).Vec3().Len(), m.Col(1).Vec3().Len(), m.Col(2).Vec3().Len()}
+	if m.Mat3().Det() < 0 {
+		scale[0] = -scale[0]
+	}
+	var rotation mgl32.Mat4
+	for i := 0; i < 3; i++ {
+		if scale[i] == 0 {
+			return translation, mgl32.QuatIdent(), scale
+		}
+		rotation.SetCol(i, m.Col(i).Mul(1/scale[i]))
+	}
+	rotation.SetCol(3, mgl32.Vec4{0, 0, 0, 1})
+	return translation, mgl32.Mat4ToQuat(rotation).Normalize(), scale
+}
@@ -0,0 +1,50 @@
+package go_world
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"math"
+)
+
+// Direct summation of Newtonian gravity between all pairs of particles.
+// Softening is added to every distance to keep close encounters finite.
+type NewtonianGravitationHandler struct {
+	G         float32
+	Softening float32
+	// Forces use the nearest periodic image when set
+	Box *PeriodicBox
+	dv  []mgl32.Vec3
+}
+
+func NewNewtonianGravitationHandler(g float32) *NewtonianGravitationHandler {
+	return &NewtonianGravitationHandler{G: g}
+}
+
+func (gh *NewtonianGravitationHandler) SetPeriodicBox(box *PeriodicBox) {
+	gh.Box = box
+}
+
+func (gh *NewtonianGravitationHandler) Apply(particles []*Particle, time_delta float32) {
+	gh.dv = gh.dv[:0]
+	for range particles {
+		gh.dv = append(gh.dv, mgl32.Vec3{})
+	}
+
+	eps2 := gh.Softening * gh.Softening
+	for i, a := range particles {
+		for j := i + 1; j < len(particles); j++ {
+			b := particles[j]
+			offset := gh.Box.Displacement(a.Position(), b.Position())
+			d2 := offset.Dot(offset) + eps2
+			if d2 == 0 {
+				continue
+			}
+			scale := gh.G * time_delta / (d2 * float32(math.Sqrt(float64(d2))))
+			gh.dv[i] = gh.dv[i].Add(offset.Mul(scale * b.Mass()))
+			gh.dv[j] = gh.dv[j].Sub(offset.Mul(scale * a.Mass()))
+		}
+	}
+
+	for i, p := range particles {
+		p.ApplyForce(gh.dv[i][0], gh.dv[i][1], gh.dv[i][2])
+	}
+}
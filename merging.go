@@ -0,0 +1,139 @@
+package go_world
+
+import (
+	"math"
+)
+
+// Collision response for accretion: touching particles merge into one,
+// conserving mass and momentum. The heavier particle survives at the common
+// center of mass and the lighter one is removed from the system.
+type MergingCollisionHandler struct {
+	// Radius of merged particles follows from their mass at this density.
+	// With a density of 0 the volume of both particles is kept instead.
+	Density float32
+	Box     *PeriodicBox
+	// Optional shared neighbour list, its cutoff has to cover the largest
+	// sum of two radii. Without one a grid is built every step.
+	Neighbours *NeighbourList
+	// Called after absorbed was merged into survivor, before it is removed
+	OnMerge func(survivor, absorbed *Particle)
+
+	system *ParticleSystem
+	grid   *spatialGrid
+}
+
+func NewMergingCollisionHandler(ps *ParticleSystem, density float32) *MergingCollisionHandler {
+	return &MergingCollisionHandler{Density: density, system: ps}
+}
+
+func (ch *MergingCollisionHandler) SetPeriodicBox(box *PeriodicBox) {
+	ch.Box = box
+}
+
+func (ch *MergingCollisionHandler) Apply(particles []*Particle) {
+	// A particle takes part in at most one merge per step, chains of
+	// touching particles merge over the following steps.
+	merged := make(map[*Particle]bool)
+	var absorbed []*Particle
+	ch.forEachPair(particles, func(a, b *Particle) {
+		if merged[a] || merged[b] {
+			return
+		}
+		offset := ch.Box.Displacement(a.Position(), b.Position())
+		if offset.Len() >= a.Radius()+b.Radius() {
+			return
+		}
+		// Pinned and kinematic particles absorb free ones, two of them
+		// cannot merge
+		fixedA, fixedB := a.InverseMass() == 0, b.InverseMass() == 0
+		if fixedA && fixedB {
+			return
+		}
+		survivor, other := a, b
+		if fixedB || (!fixedA && b.Mass() > a.Mass()) {
+			survivor, other = b, a
+		}
+		ch.merge(survivor, other)
+		merged[a] = true
+		merged[b] = true
+		absorbed = append(absorbed, other)
+	})
+
+	for _, p := range absorbed {
+		ch.system.RemoveParticle(p)
+	}
+}
+
+// Calls fn once for every pair of particles that might touch
+func (ch *MergingCollisionHandler) forEachPair(particles []*Particle, fn func(a, b *Particle)) {
+	if ch.Neighbours != nil {
+		ch.Neighbours.SetPeriodicBox(ch.Box)
+		ch.Neighbours.Update(particles)
+		ch.Neighbours.ForEachPair(fn)
+		return
+	}
+
+	maxRadius := float32(0)
+	for _, p := range particles {
+		if p.Radius() > maxRadius {
+			maxRadius = p.Radius()
+		}
+	}
+	if maxRadius == 0 {
+		return
+	}
+	if ch.grid == nil || ch.grid.cellSize != 2*maxRadius {
+		ch.grid = newSpatialGrid(2 * maxRadius)
+	}
+	ch.grid.build(particles)
+
+	index := make(map[*Particle]int, len(particles))
+	for i, p := range particles {
+		index[p] = i
+	}
+	for i, a := range particles {
+		ch.grid.forEachNearPeriodic(ch.Box, a.Position(), a.Radius()+maxRadius, func(b *Particle) {
+			if index[b] > i {
+				fn(a, b)
+			}
+		})
+	}
+}
+
+func (ch *MergingCollisionHandler) merge(survivor, absorbed *Particle) {
+	m1, m2 := survivor.Mass(), absorbed.Mass()
+	mass := m1 + m2
+
+	offset := ch.Box.Displacement(survivor.Position(), absorbed.Position())
+	position := survivor.Position().Add(offset.Mul(m2 / mass))
+	if ch.Box != nil {
+		position, _ = ch.Box.Wrap(position)
+	}
+	velocity := survivor.Velocity().Mul(m1 / mass).Add(absorbed.Velocity().Mul(m2 / mass))
+	if survivor.InverseMass() == 0 {
+		position, velocity = survivor.Position(), survivor.Velocity()
+	}
+
+	survivor.SetMass(mass)
+	survivor.SetRadius(ch.mergedRadius(survivor, absorbed))
+	survivor.SetPosition(position[0], position[1], position[2])
+	survivor.SetVelocity(velocity[0], velocity[1], velocity[2])
+
+	if ch.OnMerge != nil {
+		ch.OnMerge(survivor, absorbed)
+	}
+}
+
+// Expects the mass of survivor to be updated already
+func (ch *MergingCollisionHandler) mergedRadius(survivor, absorbed *Particle) float32 {
+	if ch.Density > 0 {
+		return radiusFromDensity(survivor.Mass(), ch.Density)
+	}
+	r1, r2 := float64(survivor.Radius()), float64(absorbed.Radius())
+	return float32(math.Cbrt(r1*r1*r1 + r2*r2*r2))
+}
+
+// Radius of a sphere with the given mass and density
+func radiusFromDensity(mass, density float32) float32 {
+	return float32(math.Cbrt(3 * float64(mass) / (4 * math.Pi * float64(density))))
+}
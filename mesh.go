@@ -0,0 +1,471 @@
+package go_world
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+	"math"
+)
+
+// The operations below change the geometry in place and return it for
+// chaining. Objects already drawing it upload the result with SetGeometry,
+// Clone keeps the original.
+
+// Per vertex data of one attribute with its number of components
+type vertexStream struct {
+	name   string
+	values *[]float32
+	size   int
+}
+
+// Attributes present for every vertex, positions first. Incomplete
+// attributes are left out like in Layout.
+func (g *Geometry) streams() []vertexStream {
+	count := int(g.vertexCount())
+	streams := []vertexStream{{PositionAttribute, &g.vertices, 3}}
+	if len(g.normals) == count*3 && count > 0 {
+		streams = append(streams, vertexStream{NormalAttribute, &g.normals, 3})
+	}
+	if len(g.uvs) == count*2 && count > 0 {
+		streams = append(streams, vertexStream{UVAttribute, &g.uvs, 2})
+	}
+	if len(g.colors) == count*4 && count > 0 {
+		streams = append(streams, vertexStream{ColorAttribute, &g.colors, 4})
+	}
+	for i := range g.custom {
+		a := &g.custom[i]
+		if len(a.values) == count*a.size && count > 0 {
+			streams = append(streams, vertexStream{a.name, &a.values, a.size})
+		}
+	}
+	return streams
+}
+
+// Rebuilds every attribute so that new vertex i is old vertex order[i]
+func (g *Geometry) reorder(order []uint32) {
+	for _, s := range g.streams() {
+		values := make([]float32, 0, len(order)*s.size)
+		for _, old := range order {
+			values = append(values, (*s.values)[int(old)*s.size:int(old+1)*s.size]...)
+		}
+		*s.values = values
+	}
+	g.invalidateBounds()
+}
+
+// Index of every corner, 0 to n-1 for geometry without indices
+func (g *Geometry) corners() []uint32 {
+	if g.indexed() {
+		return g.indices
+	}
+	corners := make([]uint32, g.vertexCount())
+	for i := range corners {
+		corners[i] = uint32(i)
+	}
+	return corners
+}
+
+func (g *Geometry) position(i uint32) mgl32.Vec3 {
+	return mgl32.Vec3{g.vertices[i*3], g.vertices[i*3+1], g.vertices[i*3+2]}
+}
+
+// Triangle list with flat normals and box mapped texture coordinates
+func NewTriangleGeometry(triangles ...Triangle) *Geometry {
+	geometry := new(Geometry)
+	geometry.vertices = t_to_array(triangles...)
+	geometry.normals = flatNormals(geometry.vertices)
+	geometry.uvs = boxUVs(geometry.vertices, geometry.normals)
+	geometry.draw_method = gl.TRIANGLES
+	return geometry
+}
+
+func (g *Geometry) Clone() *Geometry {
+	clone := *g
+	clone.vertices = append([]float32(nil), g.vertices...)
+	clone.normals = append([]float32(nil), g.normals...)
+	clone.uvs = append([]float32(nil), g.uvs...)
+	clone.colors = append([]float32(nil), g.colors...)
+	clone.indices = append([]uint32(nil), g.indices...)
+	clone.custom = make([]customAttribute, len(g.custom))
+	for i, a := range g.custom {
+		clone.custom[i] = customAttribute{a.name, a.size, append([]float32(nil), a.values...)}
+	}
+	return &clone
+}
+
+// Combines geometries drawn the same way into one. Attributes missing in
+// any of them are dropped, indices are kept if any geometry has them.
+// Strips, loops and fans can not be joined.
+func MergeGeometry(geometries ...*Geometry) (*Geometry, error) {
+	if len(geometries) == 0 {
+		return nil, errors.New("nothing to merge")
+	}
+	method := geometries[0].draw_method
+	indexed := false
+	for i, g := range geometries {
+		if g.draw_method != method {
+			return nil, fmt.Errorf("geometry %d is drawn differently", i)
+		}
+		indexed = indexed || g.indexed()
+	}
+	switch method {
+	case gl.LINE_STRIP, gl.LINE_LOOP, gl.TRIANGLE_STRIP, gl.TRIANGLE_FAN:
+		return nil, errors.New("connected primitives can not be merged")
+	}
+
+	// Attributes shared by all geometries, in the order of the first
+	shared := geometries[0].Layout()
+	for _, g := range geometries[1:] {
+		layout := g.Layout()
+		var kept VertexLayout
+		for _, a := range shared.Attributes {
+			for _, b := range layout.Attributes {
+				if a.Name == b.Name && a.Size == b.Size {
+					kept.add(a.Name, a.Size)
+					break
+				}
+			}
+		}
+		shared = kept
+	}
+
+	merged := new(Geometry)
+	merged.draw_method = method
+	for _, g := range geometries {
+		offset := uint32(merged.vertexCount())
+		for _, a := range shared.Attributes {
+			values := g.attributeValues(a.Name)
+			switch a.Name {
+			case PositionAttribute:
+				merged.vertices = append(merged.vertices, values...)
+			case NormalAttribute:
+				merged.normals = append(merged.normals, values...)
+			case UVAttribute:
+				merged.uvs = append(merged.uvs, values...)
+			case ColorAttribute:
+				merged.colors = append(merged.colors, values...)
+			default:
+				merged.SetAttribute(a.Name, a.Size, append(merged.attributeValues(a.Name), values...))
+			}
+		}
+		if indexed {
+			for _, index := range g.corners() {
+				merged.indices = append(merged.indices, index+offset)
+			}
+		}
+	}
+	return merged, nil
+}
+
+// Transforms positions by m and normals by its inverse transpose. Mirroring
+// transforms reverse the winding of triangles to keep their fronts outside.
+func (g *Geometry) Transform(m mgl32.Mat4) *Geometry {
+	for i := uint32(0); i < uint32(g.vertexCount()); i++ {
+		p := mgl32.TransformCoordinate(g.position(i), m)
+		copy(g.vertices[i*3:], p[:])
+	}
+	if len(g.normals) == len(g.vertices) {
+		normalMatrix := m.Mat3().Inv().Transpose()
+		for i := 0; i+2 < len(g.normals); i += 3 {
+			n := normalMatrix.Mul3x1(mgl32.Vec3{g.normals[i], g.normals[i+1], g.normals[i+2]})
+			if n.Len() > 0 {
+				n = n.Normalize()
+			}
+			copy(g.normals[i:], n[:])
+		}
+	}
+	if m.Mat3().Det() < 0 && g.draw_method == gl.TRIANGLES {
+		g.reverseTriangles()
+	}
+	g.invalidateBounds()
+	return g
+}
+
+// Reverses the front and back of every triangle, normals included. Like
+// the other triangle operations it leaves other draw methods unchanged.
+func (g *Geometry) FlipWinding() *Geometry {
+	if g.draw_method != gl.TRIANGLES {
+		return g
+	}
+	g.reverseTriangles()
+	for i := range g.normals {
+		g.normals[i] = -g.normals[i]
+	}
+	return g
+}
+
+func (g *Geometry) reverseTriangles() {
+	if g.indexed() {
+		for i := 0; i+2 < len(g.indices); i += 3 {
+			g.indices[i+1], g.indices[i+2] = g.indices[i+2], g.indices[i+1]
+		}
+		return
+	}
+	order := g.corners()
+	for i := 0; i+2 < len(order); i += 3 {
+		order[i+1], order[i+2] = order[i+2], order[i+1]
+	}
+	g.reorder(order)
+}
+
+// Area weighted average of the faces around every position, vertices that
+// only differ in other attributes share the normal
+func (g *Geometry) ComputeSmoothNormals() *Geometry {
+	if g.draw_method != gl.TRIANGLES {
+		return g
+	}
+	sums := make(map[mgl32.Vec3]mgl32.Vec3)
+	corners := g.corners()
+	for i := 0; i+2 < len(corners); i += 3 {
+		a, b, c := g.position(corners[i]), g.position(corners[i+1]), g.position(corners[i+2])
+		n := b.Sub(a).Cross(c.Sub(a))
+		sums[a] = sums[a].Add(n)
+		sums[b] = sums[b].Add(n)
+		sums[c] = sums[c].Add(n)
+	}
+	g.normals = make([]float32, len(g.vertices))
+	for i := uint32(0); i < uint32(g.vertexCount()); i++ {
+		if n := sums[g.position(i)]; n.Len() > 0 {
+			n = n.Normalize()
+			copy(g.normals[i*3:], n[:])
+		}
+	}
+	return g
+}
+
+// Gives every triangle its own vertices with the face normal, the geometry
+// is no longer indexed
+func (g *Geometry) ComputeFlatNormals() *Geometry {
+	if g.draw_method != gl.TRIANGLES {
+		return g
+	}
+	if g.indexed() {
+		g.reorder(g.indices)
+		g.indices = nil
+	}
+	g.normals = flatNormals(g.vertices)
+	return g
+}
+
+// Joins vertices whose attributes all differ by at most tolerance and
+// indexes the geometry. A tolerance of 0 only joins exact duplicates.
+func (g *Geometry) Weld(tolerance float32) *Geometry {
+	streams := g.streams()
+	cell := tolerance
+	if cell <= 0 {
+		cell = 1e-6
+	}
+	key := func(i uint32) [3]int64 {
+		p := g.position(i)
+		return [3]int64{
+			int64(math.Floor(float64(p[0] / cell))),
+			int64(math.Floor(float64(p[1] / cell))),
+			int64(math.Floor(float64(p[2] / cell))),
+		}
+	}
+	same := func(a, b uint32) bool {
+		for _, s := range streams {
+			values := *s.values
+			for c := 0; c < s.size; c++ {
+				if abs32(values[int(a)*s.size+c]-values[int(b)*s.size+c]) > tolerance {
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	// Vertices within tolerance can lie in neighbouring cells
+	cells := make(map[[3]int64][]uint32)
+	remap := make([]uint32, g.vertexCount())
+	var order []uint32
+	for i := uint32(0); i < uint32(g.vertexCount()); i++ {
+		k := key(i)
+		found := false
+		for dx := int64(-1); dx <= 1 && !found; dx++ {
+			for dy := int64(-1); dy <= 1 && !found; dy++ {
+				for dz := int64(-1); dz <= 1 && !found; dz++ {
+					for _, kept := range cells[[3]int64{k[0] + dx, k[1] + dy, k[2] + dz}] {
+						if same(order[kept], i) {
+							remap[i] = kept
+							found = true
+							break
+						}
+					}
+				}
+			}
+		}
+		if !found {
+			remap[i] = uint32(len(order))
+			cells[k] = append(cells[k], remap[i])
+			order = append(order, i)
+		}
+	}
+
+	corners := g.corners()
+	indices := make([]uint32, len(corners))
+	for i, corner := range corners {
+		indices[i] = remap[corner]
+	}
+	g.reorder(order)
+	g.indices = indices
+	return g
+}
+
+// Splits every triangle in four and smooths the surface with Loop's
+// scheme. Vertices at the same position count as one, so seams in normals
+// or texture coordinates stay closed while each side keeps its attributes.
+// Edges with a single triangle are kept as creases, the normals of smooth
+// geometry are recomputed.
+func (g *Geometry) Subdivide(iterations int) *Geometry {
+	if g.draw_method != gl.TRIANGLES {
+		return g
+	}
+	if !g.indexed() {
+		g.Weld(0)
+	}
+	hadNormals := len(g.normals) == len(g.vertices)
+	for n := 0; n < iterations; n++ {
+		g.loopSubdivision()
+	}
+	if hadNormals {
+		g.ComputeSmoothNormals()
+	}
+	return g
+}
+
+func (g *Geometry) loopSubdivision() {
+	type edge [2]uint32
+	edgeOf := func(a, b uint32) edge {
+		if b < a {
+			a, b = b, a
+		}
+		return edge{a, b}
+	}
+
+	// The surface is connected through positions, every vertex is
+	// represented by the first one at its position
+	count := uint32(g.vertexCount())
+	shared := make([]uint32, count)
+	first := make(map[mgl32.Vec3]uint32)
+	for v := uint32(0); v < count; v++ {
+		p := g.position(v)
+		if _, ok := first[p]; !ok {
+			first[p] = v
+		}
+		shared[v] = first[p]
+	}
+
+	// Vertices opposite to each edge, one for boundary edges
+	opposite := make(map[edge][]uint32)
+	for i := 0; i+2 < len(g.indices); i += 3 {
+		t := g.indices[i : i+3]
+		for j := 0; j < 3; j++ {
+			e := edgeOf(shared[t[j]], shared[t[(j+1)%3]])
+			opposite[e] = append(opposite[e], shared[t[(j+2)%3]])
+		}
+	}
+	neighbours := make(map[uint32][]uint32)
+	boundary := make(map[uint32][]uint32)
+	for e, others := range opposite {
+		neighbours[e[0]] = append(neighbours[e[0]], e[1])
+		neighbours[e[1]] = append(neighbours[e[1]], e[0])
+		if len(others) == 1 {
+			boundary[e[0]] = append(boundary[e[0]], e[1])
+			boundary[e[1]] = append(boundary[e[1]], e[0])
+		}
+	}
+
+	streams := g.streams()
+	// New attribute values, original vertices first
+	values := make([][]float32, len(streams))
+	for s, stream := range streams {
+		values[s] = make([]float32, int(count)*stream.size)
+	}
+	value := func(s int, v uint32) []float32 {
+		size := streams[s].size
+		return (*streams[s].values)[int(v)*size : int(v+1)*size]
+	}
+
+	for v := uint32(0); v < count; v++ {
+		for s, stream := range streams {
+			out := values[s][int(v)*stream.size : int(v+1)*stream.size]
+			if s != 0 {
+				// Only positions move, other attributes stay with the vertex
+				copy(out, value(s, v))
+				continue
+			}
+			if shared[v] != v {
+				// Computed already, copied so that rounding can not open
+				// the seam
+				copy(out, values[0][shared[v]*3:shared[v]*3+3])
+				continue
+			}
+			ring, edges := neighbours[shared[v]], boundary[shared[v]]
+			weights := map[uint32]float32{}
+			self := float32(1)
+			switch {
+			case len(edges) == 2:
+				self = 0.75
+				weights[edges[0]] += 0.125
+				weights[edges[1]] += 0.125
+			case len(edges) > 0 || len(ring) < 3:
+				// Corners of the boundary stay fixed
+			default:
+				n := float64(len(ring))
+				c := 3.0/8 + math.Cos(2*math.Pi/n)/4
+				beta := float32((5.0/8 - c*c) / n)
+				self = 1 - float32(n)*beta
+				for _, w := range ring {
+					weights[w] += beta
+				}
+			}
+			for c := 0; c < stream.size; c++ {
+				out[c] = self * value(s, v)[c]
+			}
+			for w, weight := range weights {
+				for c := 0; c < stream.size; c++ {
+					out[c] += weight * value(s, w)[c]
+				}
+			}
+		}
+	}
+
+	// Split per vertex pair, a seam gets a new vertex on either side
+	edgeVertices := make(map[edge]uint32)
+	edgeVertex := func(a, b uint32) uint32 {
+		e := edgeOf(a, b)
+		if index, ok := edgeVertices[e]; ok {
+			return index
+		}
+		index := uint32(len(values[0]) / 3)
+		edgeVertices[e] = index
+		others := opposite[edgeOf(shared[a], shared[b])]
+		for s, stream := range streams {
+			point := make([]float32, stream.size)
+			for c := range point {
+				if s == 0 && len(others) == 2 {
+					point[c] = 3.0/8*(value(s, a)[c]+value(s, b)[c]) + 1.0/8*(value(s, others[0])[c]+value(s, others[1])[c])
+				} else {
+					point[c] = (value(s, a)[c] + value(s, b)[c]) / 2
+				}
+			}
+			values[s] = append(values[s], point...)
+		}
+		return index
+	}
+
+	indices := make([]uint32, 0, len(g.indices)*4)
+	for i := 0; i+2 < len(g.indices); i += 3 {
+		a, b, c := g.indices[i], g.indices[i+1], g.indices[i+2]
+		ab, bc, ca := edgeVertex(a, b), edgeVertex(b, c), edgeVertex(c, a)
+		indices = append(indices, a, ab, ca, b, bc, ab, c, ca, bc, ab, bc, ca)
+	}
+
+	for s, stream := range streams {
+		*stream.values = values[s]
+	}
+	g.indices = indices
+	g.invalidateBounds()
+}
@@ -0,0 +1,211 @@
+package go_world
+
+import (
+	"math"
+	"sort"
+)
+
+// Scalar function of simulation time
+type Modulator interface {
+	Value(time float32) float32
+}
+
+type ModulatorFunc func(time float32) float32
+
+func (f ModulatorFunc) Value(time float32) float32 {
+	return f(time)
+}
+
+// Scales the effect of any force field with a modulator over simulation
+// time, e.g. an oscillating attractor or scheduled gusts of wind.
+type ModulatedForceField struct {
+	Field     ForceField
+	Modulator Modulator
+	time      float32
+}
+
+func NewModulatedForceField(field ForceField, modulator Modulator) *ModulatedForceField {
+	return &ModulatedForceField{Field: field, Modulator: modulator}
+}
+
+func (ff *ModulatedForceField) Time() float32 {
+	return ff.time
+}
+
+func (ff *ModulatedForceField) SetTime(time float32) {
+	ff.time = time
+}
+
+func (ff *ModulatedForceField) Advance(time_delta float32) {
+	ff.time += time_delta
+	if td, ok := ff.Field.(TimeDependent); ok {
+		td.Advance(time_delta)
+	}
+}
+
+func (ff *ModulatedForceField) Apply(p *Particle, time_delta float32) {
+	factor := ff.Modulator.Value(ff.time)
+	if factor == 0 {
+		return
+	}
+	before := p.velocity
+	ff.Field.Apply(p, time_delta)
+	p.velocity = before.Add(p.velocity.Sub(before).Mul(factor))
+}
+
+type Interpolation int
+
+const (
+	StepInterpolation Interpolation = iota
+	LinearInterpolation
+	// Cubic Hermite spline with Catmull-Rom tangents
+	CubicInterpolation
+)
+
+type Keyframe struct {
+	Time  float32
+	Value float32
+}
+
+// Interpolates between keyframes. Before the first and after the last
+// keyframe the value is held, unless Loop repeats the keyframes.
+type KeyframeModulator struct {
+	Keyframes     []Keyframe
+	Interpolation Interpolation
+	Loop          bool
+}
+
+func NewKeyframeModulator(interpolation Interpolation, keyframes ...Keyframe) *KeyframeModulator {
+	sorted := append([]Keyframe(nil), keyframes...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Time < sorted[j].Time })
+	return &KeyframeModulator{Keyframes: sorted, Interpolation: interpolation}
+}
+
+func (m *KeyframeModulator) Value(time float32) float32 {
+	keys := m.Keyframes
+	if len(keys) == 0 {
+		return 0
+	}
+	first, last := keys[0], keys[len(keys)-1]
+	if m.Loop && last.Time > first.Time {
+		span := float64(last.Time - first.Time)
+		time = first.Time + float32(math.Mod(float64(time-first.Time), span))
+		if time < first.Time {
+			time += float32(span)
+		}
+	}
+	if time <= first.Time {
+		return first.Value
+	}
+	if time >= last.Time {
+		return last.Value
+	}
+
+	i := sort.Search(len(keys), func(i int) bool { return keys[i].Time > time }) - 1
+	a, b := keys[i], keys[i+1]
+	t := (time - a.Time) / (b.Time - a.Time)
+
+	switch m.Interpolation {
+	case StepInterpolation:
+		return a.Value
+	case CubicInterpolation:
+		ma := m.tangent(i) * (b.Time - a.Time)
+		mb := m.tangent(i+1) * (b.Time - a.Time)
+		t2, t3 := t*t, t*t*t
+		return (2*t3-3*t2+1)*a.Value + (t3-2*t2+t)*ma + (-2*t3+3*t2)*b.Value + (t3-t2)*mb
+	}
+	return a.Value + (b.Value-a.Value)*t
+}
+
+// Slope at keyframe i, one sided at the ends
+func (m *KeyframeModulator) tangent(i int) float32 {
+	keys := m.Keyframes
+	prev, next := i-1, i+1
+	if prev < 0 {
+		prev = i
+	}
+	if next >= len(keys) {
+		next = i
+	}
+	if keys[next].Time == keys[prev].Time {
+		return 0
+	}
+	return (keys[next].Value - keys[prev].Value) / (keys[next].Time - keys[prev].Time)
+}
+
+type Waveform int
+
+const (
+	SineWave Waveform = iota
+	SquareWave
+	TriangleWave
+	SawtoothWave
+)
+
+// Periodic value Offset + Amplitude * wave(Frequency * time + Phase), Phase
+// is a fraction of a period.
+type Oscillator struct {
+	Waveform  Waveform
+	Amplitude float32
+	Frequency float32
+	Phase     float32
+	Offset    float32
+}
+
+func NewOscillator(amplitude, frequency, offset float32) *Oscillator {
+	return &Oscillator{Amplitude: amplitude, Frequency: frequency, Offset: offset}
+}
+
+func (o *Oscillator) Value(time float32) float32 {
+	cycle := float64(o.Frequency*time + o.Phase)
+	x := cycle - math.Floor(cycle)
+
+	var wave float64
+	switch o.Waveform {
+	case SquareWave:
+		wave = 1
+		if x >= 0.5 {
+			wave = -1
+		}
+	case TriangleWave:
+		wave = 1 - 4*math.Abs(x-0.5)
+	case SawtoothWave:
+		wave = 2*x - 1
+	default:
+		wave = math.Sin(2 * math.Pi * x)
+	}
+	return o.Offset + o.Amplitude*float32(wave)
+}
+
+type Interval struct {
+	Start float32
+	End   float32
+}
+
+// On inside of any interval and Off outside. A non zero Period repeats the
+// schedule.
+type Schedule struct {
+	Intervals []Interval
+	Period    float32
+	On        float32
+	Off       float32
+}
+
+func NewSchedule(period float32, intervals ...Interval) *Schedule {
+	return &Schedule{Intervals: intervals, Period: period, On: 1, Off: 0}
+}
+
+func (s *Schedule) Value(time float32) float32 {
+	if s.Period > 0 {
+		time = float32(math.Mod(float64(time), float64(s.Period)))
+		if time < 0 {
+			time += s.Period
+		}
+	}
+	for _, interval := range s.Intervals {
+		if time >= interval.Start && time < interval.End {
+			return s.On
+		}
+	}
+	return s.Off
+}
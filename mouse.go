@@ -0,0 +1,159 @@
+package go_world
+
+import (
+	"github.com/go-gl/glfw/v3.1/glfw"
+	"github.com/go-gl/mathgl/mgl32"
+	"math"
+)
+
+// Grabs particles with the left mouse button. While dragging, a damped
+// spring pulls the particle towards the cursor, on release the particle
+// keeps its velocity so it can be flung. The cursor moves in the plane
+// z = const through the grabbed particle. Add the dragger to the particle
+// system as force field after creating it.
+type MouseDragger struct {
+	Stiffness float32
+	Damping   float32
+	// Particles within this distance of the cursor can be grabbed even if
+	// the cursor misses them
+	PickRadius float32
+
+	system *ParticleSystem
+	camera *Camera
+	window *glfw.Window
+
+	grabbed        *Particle
+	planeZ         float32
+	cursor         mgl32.Vec3
+	cursorVelocity mgl32.Vec3
+	cursorTime     float64
+
+	previousButton glfw.MouseButtonCallback
+	previousCursor glfw.CursorPosCallback
+}
+
+// Seconds without cursor events after which the cursor counts as resting,
+// a little more than a frame
+const cursorIdleTime = 0.05
+
+func NewMouseDragger(world *World, ps *ParticleSystem) *MouseDragger {
+	dragger := new(MouseDragger)
+	dragger.Stiffness = 50
+	dragger.Damping = 5
+	dragger.PickRadius = 0.05
+	dragger.system = ps
+	dragger.camera = world.camera
+	dragger.window = world.window
+
+	dragger.previousButton = world.window.SetMouseButtonCallback(dragger.onMouseButton)
+	dragger.previousCursor = world.window.SetCursorPosCallback(dragger.onCursorPos)
+	return dragger
+}
+
+func (d *MouseDragger) Grabbed() *Particle {
+	return d.grabbed
+}
+
+// World space position the grabbed particle is pulled towards
+func (d *MouseDragger) Cursor() mgl32.Vec3 {
+	return d.cursor
+}
+
+func (d *MouseDragger) Apply(p *Particle, time_delta float32) {
+	if p != d.grabbed {
+		return
+	}
+	// GLFW only reports movement, a resting cursor sends no events
+	if glfw.GetTime()-d.cursorTime > cursorIdleTime {
+		d.cursorVelocity = mgl32.Vec3{}
+	}
+	spring := d.cursor.Sub(p.Position()).Mul(d.Stiffness)
+	damping := p.Velocity().Sub(d.cursorVelocity).Mul(d.Damping)
+	dv := spring.Sub(damping).Mul(time_delta / p.Mass())
+	p.ApplyForce(dv[0], dv[1], dv[2])
+}
+
+func (d *MouseDragger) onMouseButton(w *glfw.Window, button glfw.MouseButton, action glfw.Action, mod glfw.ModifierKey) {
+	if d.previousButton != nil {
+		d.previousButton(w, button, action, mod)
+	}
+	if button != glfw.MouseButtonLeft {
+		return
+	}
+
+	switch action {
+	case glfw.Press:
+		x, y := w.GetCursorPos()
+		d.grab(float32(x), float32(y))
+	case glfw.Release:
+		d.grabbed = nil
+		d.cursorVelocity = mgl32.Vec3{}
+	}
+}
+
+func (d *MouseDragger) onCursorPos(w *glfw.Window, x, y float64) {
+	if d.previousCursor != nil {
+		d.previousCursor(w, x, y)
+	}
+	if d.grabbed == nil {
+		return
+	}
+	position, ok := d.cursorOnPlane(float32(x), float32(y), d.planeZ)
+	if !ok {
+		return
+	}
+
+	now := glfw.GetTime()
+	if elapsed := float32(now - d.cursorTime); elapsed > 0 {
+		// Smoothed, single events are noisy
+		velocity := position.Sub(d.cursor).Mul(1 / elapsed)
+		d.cursorVelocity = d.cursorVelocity.Mul(0.5).Add(velocity.Mul(0.5))
+	}
+	d.cursor = position
+	d.cursorTime = now
+}
+
+func (d *MouseDragger) grab(x, y float32) {
+	width, height := d.window.GetSize()
+	origin, direction := d.camera.ScreenRay(x, y, width, height)
+
+	particle, _ := d.system.Raycast(origin, direction, 0)
+	if particle == nil && d.PickRadius > 0 {
+		if point, ok := rayPlaneZ(origin, direction, 0); ok {
+			nearest := d.system.KNearest(point, 1)
+			if len(nearest) == 1 && nearest[0].Position().Sub(point).Len() <= nearest[0].Radius()+d.PickRadius {
+				particle = nearest[0]
+			}
+		}
+	}
+	if particle == nil {
+		return
+	}
+
+	d.grabbed = particle
+	d.planeZ = particle.Position().Z()
+	d.cursor = particle.Position()
+	if position, ok := rayPlaneZ(origin, direction, d.planeZ); ok {
+		d.cursor = position
+	}
+	d.cursorVelocity = mgl32.Vec3{}
+	d.cursorTime = glfw.GetTime()
+}
+
+func (d *MouseDragger) cursorOnPlane(x, y, z float32) (mgl32.Vec3, bool) {
+	width, height := d.window.GetSize()
+	origin, direction := d.camera.ScreenRay(x, y, width, height)
+	return rayPlaneZ(origin, direction, z)
+}
+
+// Intersection of a ray with the plane of constant z
+func rayPlaneZ(origin, direction mgl32.Vec3, z float32) (mgl32.Vec3, bool) {
+	if math.Abs(float64(direction.Z())) < 1e-6 {
+		return mgl32.Vec3{}, false
+	}
+	t := (z - origin.Z()) / direction.Z()
+	if t < 0 {
+		return mgl32.Vec3{}, false
+	}
+	return origin.Add(direction.Mul(t)), true
+}
@@ -0,0 +1,112 @@
+package go_world
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Verlet neighbour list. It holds all pairs closer than Cutoff + Skin and is
+// only rebuilt once a particle moved more than half the skin or the set of
+// particles changed, so pairs within Cutoff are never missed in between.
+// One list can be shared by several handlers as long as its cutoff covers
+// all of their interaction ranges.
+type NeighbourList struct {
+	Cutoff float32
+	Skin   float32
+	Box    *PeriodicBox
+
+	particles  []*Particle
+	reference  []mgl32.Vec3
+	pairs      [][2]*Particle
+	neighbours map[*Particle][]*Particle
+	grid       *spatialGrid
+	rebuilds   int
+}
+
+func NewNeighbourList(cutoff, skin float32) *NeighbourList {
+	return &NeighbourList{Cutoff: cutoff, Skin: skin}
+}
+
+func (nl *NeighbourList) SetPeriodicBox(box *PeriodicBox) {
+	nl.Box = box
+}
+
+// Rebuilds the list if necessary and reports whether it did
+func (nl *NeighbourList) Update(particles []*Particle) bool {
+	if !nl.stale(particles) {
+		return false
+	}
+	nl.rebuild(particles)
+	return true
+}
+
+// Pairs closer than Cutoff + Skin at the last rebuild, each pair once
+func (nl *NeighbourList) Pairs() [][2]*Particle {
+	return nl.pairs
+}
+
+func (nl *NeighbourList) ForEachPair(fn func(a, b *Particle)) {
+	for _, pair := range nl.pairs {
+		fn(pair[0], pair[1])
+	}
+}
+
+func (nl *NeighbourList) Neighbours(p *Particle) []*Particle {
+	return nl.neighbours[p]
+}
+
+// Number of rebuilds so far
+func (nl *NeighbourList) Rebuilds() int {
+	return nl.rebuilds
+}
+
+func (nl *NeighbourList) stale(particles []*Particle) bool {
+	if nl.neighbours == nil || len(particles) != len(nl.particles) {
+		return true
+	}
+	limit := nl.Skin / 2
+	for i, p := range particles {
+		if p != nl.particles[i] {
+			return true
+		}
+		if nl.Box.Displacement(nl.reference[i], p.Position()).Len() > limit {
+			return true
+		}
+	}
+	return false
+}
+
+func (nl *NeighbourList) rebuild(particles []*Particle) {
+	reach := nl.Cutoff + nl.Skin
+	if nl.grid == nil || nl.grid.cellSize != reach {
+		nl.grid = newSpatialGrid(reach)
+	}
+	nl.grid.build(particles)
+
+	nl.particles = append(nl.particles[:0], particles...)
+	nl.reference = nl.reference[:0]
+	index := make(map[*Particle]int, len(particles))
+	for i, p := range particles {
+		nl.reference = append(nl.reference, p.Position())
+		index[p] = i
+	}
+
+	nl.pairs = nl.pairs[:0]
+	nl.neighbours = make(map[*Particle][]*Particle, len(particles))
+	for i, a := range particles {
+		// A pair must not be listed twice, whatever the grid visits
+		seen := make(map[*Particle]bool)
+		nl.grid.forEachNearPeriodic(nl.Box, a.Position(), reach, func(b *Particle) {
+			if index[b] <= i || seen[b] {
+				return
+			}
+			seen[b] = true
+			if nl.Box.Displacement(a.Position(), b.Position()).Len() >= reach {
+				return
+			}
+			nl.pairs = append(nl.pairs, [2]*Particle{a, b})
+			nl.neighbours[a] = append(nl.neighbours[a], b)
+			nl.neighbours[b] = append(nl.neighbours[b], a)
+		})
+	}
+	nl.rebuilds++
+}
@@ -0,0 +1,39 @@
+package go_world
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"testing"
+)
+
+// The reach of the list exceeds half the box, so the periodic images of a
+// query overlap
+func TestNeighbourListSmallPeriodicBox(t *testing.T) {
+	ps := NewParticleSystem(NewScene(0))
+	ps.NewParticle().SetPosition(0.1, 0.5, 0.5)
+	ps.NewParticle().SetPosition(1.25, 0.5, 0.5)
+	ps.NewParticle().SetPosition(2.45, 2, 2)
+	ps.SetPeriodicBox(NewPeriodicBox(mgl32.Vec3{0, 0, 0}, mgl32.Vec3{2.5, 2.5, 2.5}))
+
+	unit := PotentialFunc(func(r float32) (float32, float32) {
+		return 0, 1
+	})
+	handler := NewPairInteractionHandler(unit, 1.2)
+	ps.AddInteractionHandler(handler)
+	ps.Update(0.001)
+
+	// Two pairs lie within the cutoff plus skin, each listed once
+	pairs := handler.neighbourList().Pairs()
+	seen := make(map[[2]*Particle]bool)
+	for _, pair := range pairs {
+		if seen[pair] || seen[[2]*Particle{pair[1], pair[0]}] {
+			t.Errorf("pair listed twice")
+		}
+		seen[pair] = true
+	}
+	if len(pairs) != 2 {
+		t.Errorf("got %d pairs, expected 2", len(pairs))
+	}
+	if energy := handler.PotentialEnergy(); energy != 1 {
+		t.Errorf("got energy %v, expected 1", energy)
+	}
+}
@@ -0,0 +1,72 @@
+package go_world
+
+import (
+	"math"
+	"math/rand"
+)
+
+// Perlin gradient noise in three dimensions, values lie roughly in [-1, 1].
+type gradientNoise struct {
+	permutation [512]int
+}
+
+func newGradientNoise(seed int64) *gradientNoise {
+	noise := new(gradientNoise)
+	order := rand.New(rand.NewSource(seed)).Perm(256)
+	for i := 0; i < 512; i++ {
+		noise.permutation[i] = order[i%256]
+	}
+	return noise
+}
+
+func (n *gradientNoise) at(x, y, z float64) float64 {
+	xi, yi, zi := int(math.Floor(x))&255, int(math.Floor(y))&255, int(math.Floor(z))&255
+	x -= math.Floor(x)
+	y -= math.Floor(y)
+	z -= math.Floor(z)
+	u, v, w := fade(x), fade(y), fade(z)
+
+	p := n.permutation
+	a := p[xi] + yi
+	aa, ab := p[a]+zi, p[a+1]+zi
+	b := p[xi+1] + yi
+	ba, bb := p[b]+zi, p[b+1]+zi
+
+	return lerp64(w,
+		lerp64(v,
+			lerp64(u, grad(p[aa], x, y, z), grad(p[ba], x-1, y, z)),
+			lerp64(u, grad(p[ab], x, y-1, z), grad(p[bb], x-1, y-1, z))),
+		lerp64(v,
+			lerp64(u, grad(p[aa+1], x, y, z-1), grad(p[ba+1], x-1, y, z-1)),
+			lerp64(u, grad(p[ab+1], x, y-1, z-1), grad(p[bb+1], x-1, y-1, z-1))))
+}
+
+func fade(t float64) float64 {
+	return t * t * t * (t*(t*6-15) + 10)
+}
+
+func lerp64(t, a, b float64) float64 {
+	return a + t*(b-a)
+}
+
+// Dot product with one of twelve gradient directions picked by hash
+func grad(hash int, x, y, z float64) float64 {
+	h := hash & 15
+	u := y
+	if h < 8 {
+		u = x
+	}
+	v := z
+	if h < 4 {
+		v = y
+	} else if h == 12 || h == 14 {
+		v = x
+	}
+	if h&1 != 0 {
+		u = -u
+	}
+	if h&2 != 0 {
+		v = -v
+	}
+	return u + v
+}
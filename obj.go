@@ -0,0 +1,466 @@
+package go_world
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Surface description from an MTL file
+type Material struct {
+	Name      string
+	Ambient   mgl32.Vec3
+	Diffuse   mgl32.Vec3
+	Specular  mgl32.Vec3
+	Shininess float32
+	Opacity   float32
+	// Texture file of the diffuse colour as written in the file
+	DiffuseMap string
+}
+
+func NewMaterial(name string) *Material {
+	return &Material{Name: name, Diffuse: mgl32.Vec3{0.8, 0.8, 0.8}, Opacity: 1}
+}
+
+// Faces of an OBJ file sharing group and material, as indexed triangles.
+// Vertices are coloured with the diffuse colour of the material.
+type OBJGroup struct {
+	Name         string
+	MaterialName string
+	Material     *Material
+	Geometry     *Geometry
+}
+
+type OBJModel struct {
+	Groups    []*OBJGroup
+	Materials map[string]*Material
+}
+
+// Loads an OBJ file and the material libraries it references, which are
+// looked up relative to the file.
+func LoadOBJFile(path string) (*OBJModel, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	dir := filepath.Dir(path)
+	model, err := LoadOBJ(file, func(name string) (io.ReadCloser, error) {
+		return os.Open(filepath.Join(dir, name))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return model, nil
+}
+
+// Parses positions, texture coordinates, normals and faces, polygons are
+// split into triangles. Every g, o or usemtl statement starts a new group.
+// openMaterials opens the files named by mtllib, nil skips them. Faces
+// without normals get smooth normals averaged over their group.
+func LoadOBJ(r io.Reader, openMaterials func(name string) (io.ReadCloser, error)) (*OBJModel, error) {
+	parser := &objParser{model: &OBJModel{Materials: make(map[string]*Material)}}
+	parser.startGroup("default", "")
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := objFields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if err := parser.statement(fields, openMaterials); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	parser.finishGroup()
+	for _, group := range parser.model.Groups {
+		group.Material = parser.model.Materials[group.MaterialName]
+		if group.Material != nil {
+			c := group.Material.Diffuse.Vec4(group.Material.Opacity)
+			colors := make([]mgl32.Vec4, group.Geometry.vertexCount())
+			for i := range colors {
+				colors[i] = c
+			}
+			group.Geometry.SetColors(colors...)
+		}
+	}
+	return parser.model, nil
+}
+
+// Reads the materials of an MTL file by name
+func LoadMTL(r io.Reader) (map[string]*Material, error) {
+	materials := make(map[string]*Material)
+	var current *Material
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := objFields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] != "newmtl" && current == nil {
+			return nil, fmt.Errorf("line %d: %s before newmtl", line, fields[0])
+		}
+
+		var err error
+		switch fields[0] {
+		case "newmtl":
+			if len(fields) < 2 {
+				err = fmt.Errorf("newmtl needs a name")
+				break
+			}
+			current = NewMaterial(strings.Join(fields[1:], " "))
+			materials[current.Name] = current
+		case "Ka":
+			current.Ambient, err = parseVec3(fields[1:])
+		case "Kd":
+			current.Diffuse, err = parseVec3(fields[1:])
+		case "Ks":
+			current.Specular, err = parseVec3(fields[1:])
+		case "Ns":
+			current.Shininess, err = parseScalar(fields[1:])
+		case "d":
+			current.Opacity, err = parseScalar(fields[1:])
+		case "Tr":
+			var transparency float32
+			transparency, err = parseScalar(fields[1:])
+			current.Opacity = 1 - transparency
+		case "map_Kd":
+			if len(fields) < 2 {
+				err = fmt.Errorf("map_Kd needs a file name")
+				break
+			}
+			// Options like -s come before the file name
+			current.DiffuseMap = fields[len(fields)-1]
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return materials, nil
+}
+
+// Fields of a line without the comment
+func objFields(line string) []string {
+	if i := strings.IndexByte(line, '#'); i >= 0 {
+		line = line[:i]
+	}
+	return strings.Fields(line)
+}
+
+func parseVec3(fields []string) (mgl32.Vec3, error) {
+	if len(fields) < 3 {
+		return mgl32.Vec3{}, fmt.Errorf("expected 3 numbers, got %d", len(fields))
+	}
+	values, err := parseFloats(fields[:3])
+	if err != nil {
+		return mgl32.Vec3{}, err
+	}
+	return mgl32.Vec3{values[0], values[1], values[2]}, nil
+}
+
+func parseScalar(fields []string) (float32, error) {
+	if len(fields) < 1 {
+		return 0, fmt.Errorf("expected a number")
+	}
+	values, err := parseFloats(fields[:1])
+	if err != nil {
+		return 0, err
+	}
+	return values[0], nil
+}
+
+// Indices into the position, texture coordinate and normal lists of the
+// file, -1 if absent
+type objVertex struct {
+	position, uv, normal int
+}
+
+type objParser struct {
+	model     *OBJModel
+	positions []mgl32.Vec3
+	uvs       []mgl32.Vec2
+	normals   []mgl32.Vec3
+
+	group    *OBJGroup
+	vertices map[objVertex]uint32
+	order    []objVertex
+	indices  []uint32
+}
+
+func (op *objParser) statement(fields []string, openMaterials func(name string) (io.ReadCloser, error)) error {
+	switch fields[0] {
+	case "v":
+		position, err := parseVec3(fields[1:])
+		if err != nil {
+			return err
+		}
+		op.positions = append(op.positions, position)
+	case "vt":
+		if len(fields) < 2 {
+			return fmt.Errorf("vt needs at least 1 number")
+		}
+		if len(fields) > 3 {
+			// Optional depth of 3D textures
+			fields = fields[:3]
+		}
+		values, err := parseFloats(fields[1:])
+		if err != nil {
+			return err
+		}
+		uv := mgl32.Vec2{values[0], 0}
+		if len(values) > 1 {
+			uv[1] = values[1]
+		}
+		op.uvs = append(op.uvs, uv)
+	case "vn":
+		normal, err := parseVec3(fields[1:])
+		if err != nil {
+			return err
+		}
+		op.normals = append(op.normals, normal)
+	case "f":
+		return op.face(fields[1:])
+	case "g", "o":
+		name := "default"
+		if len(fields) > 1 {
+			name = strings.Join(fields[1:], " ")
+		}
+		op.startGroup(name, op.group.MaterialName)
+	case "usemtl":
+		if len(fields) < 2 {
+			return fmt.Errorf("usemtl needs a material name")
+		}
+		op.startGroup(op.group.Name, strings.Join(fields[1:], " "))
+	case "mtllib":
+		if openMaterials == nil {
+			return nil
+		}
+		for _, name := range fields[1:] {
+			if err := op.loadMaterials(name, openMaterials); err != nil {
+				return err
+			}
+		}
+	}
+	// Other statements like smoothing groups, lines and free form surfaces
+	// are not supported and skipped
+	return nil
+}
+
+func (op *objParser) loadMaterials(name string, openMaterials func(name string) (io.ReadCloser, error)) error {
+	file, err := openMaterials(name)
+	if err != nil {
+		return fmt.Errorf("material library: %v", err)
+	}
+	defer file.Close()
+
+	materials, err := LoadMTL(file)
+	if err != nil {
+		return fmt.Errorf("material library %s: %v", name, err)
+	}
+	for name, material := range materials {
+		op.model.Materials[name] = material
+	}
+	return nil
+}
+
+func (op *objParser) face(fields []string) error {
+	if len(fields) < 3 {
+		return fmt.Errorf("face needs at least 3 vertices, got %d", len(fields))
+	}
+	corners := make([]uint32, len(fields))
+	points := make([]mgl32.Vec3, len(fields))
+	for i, field := range fields {
+		vertex, err := op.vertex(field)
+		if err != nil {
+			return err
+		}
+		index, ok := op.vertices[vertex]
+		if !ok {
+			index = uint32(len(op.order))
+			op.vertices[vertex] = index
+			op.order = append(op.order, vertex)
+		}
+		corners[i] = index
+		points[i] = op.positions[vertex.position]
+	}
+	for _, corner := range triangulateFace(points) {
+		op.indices = append(op.indices, corners[corner])
+	}
+	return nil
+}
+
+// Triangles of a planar polygon as indices into its corners, keeping its
+// winding. Polygons may be concave. Ones that are not simple or have no
+// area fall back to a fan around the first corner.
+func triangulateFace(points []mgl32.Vec3) []uint32 {
+	var fan []uint32
+	for i := 1; i+1 < len(points); i++ {
+		fan = append(fan, 0, uint32(i), uint32(i+1))
+	}
+	if len(points) == 3 {
+		return fan
+	}
+
+	// Newell's normal, which also works for concave polygons
+	var normal mgl32.Vec3
+	for i, p := range points {
+		q := points[(i+1)%len(points)]
+		normal = normal.Add(mgl32.Vec3{
+			(p[1] - q[1]) * (p[2] + q[2]),
+			(p[2] - q[2]) * (p[0] + q[0]),
+			(p[0] - q[0]) * (p[1] + q[1]),
+		})
+	}
+	if normal.Len() == 0 {
+		return fan
+	}
+	normal = normal.Normalize()
+
+	// Axes of the plane, counter clockwise seen from the front
+	axis := mgl32.Vec3{1, 0, 0}
+	if abs32(normal[0]) > 0.9 {
+		axis = mgl32.Vec3{0, 1, 0}
+	}
+	u := axis.Cross(normal).Normalize()
+	v := normal.Cross(u)
+	outline := make([]mgl32.Vec2, len(points))
+	for i, p := range points {
+		outline[i] = mgl32.Vec2{p.Dot(u), p.Dot(v)}
+	}
+
+	triangles, err := TriangulatePolygon(outline)
+	if err != nil {
+		return fan
+	}
+	// TriangulatePolygon turns every triangle counter clockwise in the
+	// plane, which is the winding of the face around its Newell normal
+	return triangles
+}
+
+// Parses v, v/vt, v//vn or v/vt/vn, negative indices count from the end
+func (op *objParser) vertex(field string) (objVertex, error) {
+	parts := strings.Split(field, "/")
+	if len(parts) > 3 {
+		return objVertex{}, fmt.Errorf("invalid face vertex %q", field)
+	}
+	vertex := objVertex{-1, -1, -1}
+	lists := []struct {
+		target *int
+		count  int
+		name   string
+	}{
+		{&vertex.position, len(op.positions), "position"},
+		{&vertex.uv, len(op.uvs), "texture coordinate"},
+		{&vertex.normal, len(op.normals), "normal"},
+	}
+	for i, part := range parts {
+		if part == "" {
+			if i == 0 {
+				return objVertex{}, fmt.Errorf("face vertex %q has no position", field)
+			}
+			continue
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return objVertex{}, fmt.Errorf("invalid index %q in face vertex %q", part, field)
+		}
+		index := n - 1
+		if n < 0 {
+			index = lists[i].count + n
+		}
+		if n == 0 || index < 0 || index >= lists[i].count {
+			return objVertex{}, fmt.Errorf("%s index %d out of range (%d defined)", lists[i].name, n, lists[i].count)
+		}
+		*lists[i].target = index
+	}
+	return vertex, nil
+}
+
+// Closes the current group and opens the next. Groups without faces are
+// dropped.
+func (op *objParser) startGroup(name, material string) {
+	op.finishGroup()
+	op.group = &OBJGroup{Name: name, MaterialName: material}
+	op.vertices = make(map[objVertex]uint32)
+	op.order = nil
+	op.indices = nil
+}
+
+func (op *objParser) finishGroup() {
+	if op.group == nil || len(op.indices) == 0 {
+		return
+	}
+
+	positions := make([]mgl32.Vec3, len(op.order))
+	normals := make([]mgl32.Vec3, len(op.order))
+	uvs := make([]mgl32.Vec2, len(op.order))
+	hasUVs := false
+	missingNormals := false
+	for i, vertex := range op.order {
+		positions[i] = op.positions[vertex.position]
+		if vertex.uv >= 0 {
+			uvs[i] = op.uvs[vertex.uv]
+			hasUVs = true
+		}
+		if vertex.normal >= 0 {
+			normals[i] = op.normals[vertex.normal]
+		} else {
+			missingNormals = true
+		}
+	}
+	if missingNormals {
+		op.smoothNormals(positions, normals)
+	}
+
+	geometry := new(Geometry)
+	geometry.vertices = to_array(positions...)
+	geometry.SetNormals(normals...)
+	if hasUVs {
+		geometry.SetUVs(uvs...)
+	}
+	geometry.indices = op.indices
+	geometry.draw_method = gl.TRIANGLES
+	op.group.Geometry = geometry
+	op.model.Groups = append(op.model.Groups, op.group)
+}
+
+// Area weighted average of the normals of the faces around vertices that
+// have none, faces around the same position contribute
+func (op *objParser) smoothNormals(positions, normals []mgl32.Vec3) {
+	sums := make(map[int]mgl32.Vec3)
+	for i := 0; i+2 < len(op.indices); i += 3 {
+		a, b, c := op.indices[i], op.indices[i+1], op.indices[i+2]
+		n := positions[b].Sub(positions[a]).Cross(positions[c].Sub(positions[a]))
+		for _, corner := range []uint32{a, b, c} {
+			position := op.order[corner].position
+			sums[position] = sums[position].Add(n)
+		}
+	}
+	for i, vertex := range op.order {
+		if vertex.normal >= 0 {
+			continue
+		}
+		if n := sums[vertex.position]; n.Len() > 0 {
+			normals[i] = n.Normalize()
+		}
+	}
+}
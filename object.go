@@ -8,14 +8,21 @@ import (
 type Object struct {
 	geometry *Geometry
 	position mgl32.Vec3
-	rotation mgl32.Vec3
+	rotation mgl32.Quat
 	scale    mgl32.Vec3
+	parent   *Object
+	// Set by the 3D transform setters, see ModelMatrix
+	spatial bool
 
 	model        mgl32.Mat4
 	modelUniform int32
 	angle        float64
 	vao          uint32
 	vbo          uint32
+	ebo          uint32
+	layout       VertexLayout
+	program      uint32
+	indexType    uint32
 }
 
 func NewObject(geometry *Geometry) *Object {
@@ -23,7 +30,7 @@ func NewObject(geometry *Geometry) *Object {
 	object.geometry = geometry
 
 	object.position = mgl32.Vec3{0, 0, 0}
-	object.rotation = mgl32.Vec3{0, 0, 0}
+	object.rotation = mgl32.QuatIdent()
 	object.scale = mgl32.Vec3{1, 1, 1}
 
 	object.angle = 0
@@ -47,6 +54,60 @@ func (o Object) Position() mgl32.Vec3 {
 	return o.position
 }
 
+func (o *Object) SetRotation(rotation mgl32.Quat) *Object {
+	o.rotation = rotation
+	o.spatial = true
+	return o
+}
+
+func (o *Object) Rotation() mgl32.Quat {
+	return o.rotation
+}
+
+// Scales the axes independently
+func (o *Object) SetScaleXYZ(x, y, z float32) *Object {
+	o.scale = mgl32.Vec3{x, y, z}
+	o.spatial = true
+	return o
+}
+
+func (o *Object) Scale() mgl32.Vec3 {
+	return o.scale
+}
+
+// Position, rotation and scale become relative to parent. Parents only
+// provide a transform, they are not drawn unless added to a scene as well.
+func (o *Object) SetParent(parent *Object) *Object {
+	o.parent = parent
+	o.spatial = true
+	return o
+}
+
+func (o *Object) Parent() *Object {
+	return o.parent
+}
+
+// Transform from object to world space. Objects only placed with
+// SetPosition and SetScale keep the original flat transform, which drops z
+// from the scale and scales the translation as well. Using SetRotation,
+// SetScaleXYZ or SetParent switches to the full 3D transform: scale, then
+// rotation, then translation, followed by the transforms of all parents.
+func (o *Object) ModelMatrix() mgl32.Mat4 {
+	angle := mgl32.HomogRotate3D(float32(o.angle), mgl32.Vec3{0, 1, 0})
+	translation := mgl32.Translate3D(o.position[0], o.position[1], o.position[2])
+	if !o.spatial {
+		return mgl32.Scale3D(o.scale[0], o.scale[1], 0).Mul4(translation).Mul4(angle)
+	}
+	model := translation.
+		Mul4(o.rotation.Mat4()).
+		Mul4(angle).
+		Mul4(mgl32.Scale3D(o.scale[0], o.scale[1], o.scale[2]))
+	if o.parent != nil {
+		model = o.parent.ModelMatrix().Mul4(model)
+	}
+	return model
+}
+
 func (object *Object) Configure(program uint32) {
 	object.configure(program)
 }
@@ -55,18 +116,71 @@ func (object *Object) configure(program uint32) {
 	gl.GenVertexArrays(1, &object.vao)
 	gl.BindVertexArray(object.vao)
 
-	var vbo uint32
-	gl.GenBuffers(1, &vbo)
-	gl.BindBuffer(gl.ARRAY_BUFFER, vbo)
-	gl.BufferData(gl.ARRAY_BUFFER, len(object.geometry.vertices)*5, gl.Ptr(object.geometry.vertices), gl.STATIC_DRAW)
-	object.vbo = vbo
+	gl.GenBuffers(1, &object.vbo)
+	object.program = program
+	object.uploadVertices(gl.STATIC_DRAW)
 
-	vertAttrib := uint32(gl.GetAttribLocation(program, gl.Str("position\x00")))
-	gl.EnableVertexAttribArray(vertAttrib)
-	gl.VertexAttribPointer(vertAttrib, 3, gl.FLOAT, false, 0, gl.PtrOffset(0))
+	if object.geometry.indexed() {
+		object.uploadIndices(gl.STATIC_DRAW)
+	}
 
 	// Configure global settings
     gl.Enable(gl.DEPTH_TEST)
     gl.DepthFunc(gl.LESS)
+	gl.Enable(gl.BLEND)
+	gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
 	gl.ClearColor(1.0, 1.0, 1.0, 1.0)
 }
+
+// Replaces the geometry of an object in place. A configured object keeps its
+// buffers and position in the scene, only their contents are uploaded again.
+func (object *Object) SetGeometry(geometry *Geometry) {
+	object.geometry = geometry
+	if object.vao != 0 {
+		object.updateBuffers(gl.STATIC_DRAW)
+	}
+}
+
+func (object *Object) Geometry() *Geometry {
+	return object.geometry
+}
+
+// Uploads the current geometry again. Dynamic geometry like trails passes
+// gl.DYNAMIC_DRAW as usage.
+func (object *Object) updateBuffers(usage uint32) {
+	gl.BindVertexArray(object.vao)
+
+	object.uploadVertices(usage)
+
+	if object.geometry.indexed() {
+		object.uploadIndices(usage)
+	}
+}
+
+// Interleaves the attributes of the geometry into the vertex buffer and
+// binds them again if the layout changed. Expects the vao to be bound.
+func (object *Object) uploadVertices(usage uint32) {
+	layout := object.geometry.Layout()
+	data := object.geometry.interleave(layout)
+
+	gl.BindBuffer(gl.ARRAY_BUFFER, object.vbo)
+	gl.BufferData(gl.ARRAY_BUFFER, len(data)*4, gl.Ptr(data), usage)
+
+	if !layout.Equal(object.layout) {
+		unbindVertexLayout(object.program, object.layout)
+		bindVertexLayout(object.program, layout)
+		object.layout = layout
+	}
+}
+
+// The element buffer is part of the vertex array state, expects the vao to
+// be bound. Created on first use.
+func (object *Object) uploadIndices(usage uint32) {
+	if object.ebo == 0 {
+		gl.GenBuffers(1, &object.ebo)
+	}
+	indexType, size, data := object.geometry.elementData()
+	object.indexType = indexType
+	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, object.ebo)
+	gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, size, data, usage)
+}
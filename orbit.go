@@ -0,0 +1,206 @@
+package go_world
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"math"
+)
+
+// Classical Keplerian elements of a two body orbit. Angles are in radians,
+// the reference plane is the xy plane and the reference direction the x axis.
+type OrbitalElements struct {
+	SemiMajorAxis            float32 // a
+	Eccentricity             float32 // e
+	Inclination              float32 // i
+	LongitudeOfAscendingNode float32 // Ω
+	ArgumentOfPeriapsis      float32 // ω
+	TrueAnomaly              float32 // ν
+}
+
+const orbitEpsilon = 1e-6
+
+// Places p on a circular orbit of the given radius around central in the xy
+// plane. g is the gravitational constant of the simulation.
+func (p *Particle) SetCircularOrbit(central *Particle, radius, g float32) *Particle {
+	return p.SetOrbit(central, OrbitalElements{SemiMajorAxis: radius}, g)
+}
+
+// Sets position and velocity of p so that it follows the orbit described by
+// elements around central. Only p is changed, the momentum of central is left
+// as it is.
+func (p *Particle) SetOrbit(central *Particle, elements OrbitalElements, g float32) *Particle {
+	mu := float64(g) * float64(p.Mass()+central.Mass())
+	position, velocity := orbitalStateVectors(elements, mu)
+
+	cp := central.Position()
+	cv := central.Velocity()
+	p.SetPosition(cp[0]+position[0], cp[1]+position[1], cp[2]+position[2])
+	p.SetVelocity(cv[0]+velocity[0], cv[1]+velocity[1], cv[2]+velocity[2])
+	return p
+}
+
+// Computes the current orbital elements of p relative to central.
+func ComputeOrbitalElements(p, central *Particle, g float32) OrbitalElements {
+	mu := float64(g) * float64(p.Mass()+central.Mass())
+	return orbitalElementsFromState(
+		p.Position().Sub(central.Position()),
+		p.Velocity().Sub(central.Velocity()),
+		mu,
+	)
+}
+
+// Orbital period of an elliptic orbit with semi-major axis a around a pair of
+// bodies with the combined mass m.
+func OrbitalPeriod(a, m, g float32) float32 {
+	mu := float64(g) * float64(m)
+	return float32(2 * math.Pi * math.Sqrt(math.Pow(float64(a), 3)/mu))
+}
+
+func orbitalStateVectors(elements OrbitalElements, mu float64) (mgl32.Vec3, mgl32.Vec3) {
+	a := float64(elements.SemiMajorAxis)
+	e := float64(elements.Eccentricity)
+	nu := float64(elements.TrueAnomaly)
+
+	// Semi-latus rectum, a is negative for hyperbolic orbits
+	semiLatus := a * (1 - e*e)
+	r := semiLatus / (1 + e*math.Cos(nu))
+	speed := math.Sqrt(mu / semiLatus)
+
+	// Perifocal frame
+	position := [3]float64{r * math.Cos(nu), r * math.Sin(nu), 0}
+	velocity := [3]float64{-speed * math.Sin(nu), speed * (e + math.Cos(nu)), 0}
+
+	rotation := perifocalToReference(
+		float64(elements.LongitudeOfAscendingNode),
+		float64(elements.Inclination),
+		float64(elements.ArgumentOfPeriapsis),
+	)
+	return rotation.apply(position), rotation.apply(velocity)
+}
+
+func orbitalElementsFromState(position, velocity mgl32.Vec3, mu float64) OrbitalElements {
+	r := vec3ToFloat64(position)
+	v := vec3ToFloat64(velocity)
+
+	rLen := length64(r)
+	vLen := length64(v)
+	h := cross64(r, v)
+	hLen := length64(h)
+	node := [3]float64{-h[1], h[0], 0}
+	nodeLen := length64(node)
+
+	rv := dot64(r, v)
+	eVec := [3]float64{}
+	for i := range eVec {
+		eVec[i] = ((vLen*vLen-mu/rLen)*r[i] - rv*v[i]) / mu
+	}
+	e := length64(eVec)
+
+	energy := vLen*vLen/2 - mu/rLen
+	var a float64
+	if math.Abs(energy) > orbitEpsilon {
+		a = -mu / (2 * energy)
+	} else {
+		a = math.Inf(1)
+	}
+
+	inclination := math.Acos(clamp64(h[2]/hLen, -1, 1))
+
+	var ascendingNode, periapsis, anomaly float64
+	equatorial := nodeLen < orbitEpsilon*hLen
+	circular := e < orbitEpsilon
+
+	if !equatorial {
+		ascendingNode = math.Atan2(node[1], node[0])
+	}
+
+	switch {
+	case circular && equatorial:
+		// Measured from the reference direction
+		anomaly = math.Atan2(r[1], r[0])
+		if h[2] < 0 {
+			anomaly = -anomaly
+		}
+	case circular:
+		// Argument of latitude, measured from the ascending node
+		anomaly = signedAngle64(node, r, h)
+	case equatorial:
+		periapsis = math.Atan2(eVec[1], eVec[0])
+		if h[2] < 0 {
+			periapsis = -periapsis
+		}
+		anomaly = signedAngle64(eVec, r, h)
+	default:
+		periapsis = signedAngle64(node, eVec, h)
+		anomaly = signedAngle64(eVec, r, h)
+	}
+
+	return OrbitalElements{
+		SemiMajorAxis:            float32(a),
+		Eccentricity:             float32(e),
+		Inclination:              float32(inclination),
+		LongitudeOfAscendingNode: float32(normalizeAngle64(ascendingNode)),
+		ArgumentOfPeriapsis:      float32(normalizeAngle64(periapsis)),
+		TrueAnomaly:              float32(normalizeAngle64(anomaly)),
+	}
+}
+
+type rotation64 [3][3]float64
+
+// Rz(Ω) * Rx(i) * Rz(ω)
+func perifocalToReference(ascendingNode, inclination, periapsis float64) rotation64 {
+	cO, sO := math.Cos(ascendingNode), math.Sin(ascendingNode)
+	ci, si := math.Cos(inclination), math.Sin(inclination)
+	cw, sw := math.Cos(periapsis), math.Sin(periapsis)
+
+	return rotation64{
+		{cO*cw - sO*sw*ci, -cO*sw - sO*cw*ci, sO * si},
+		{sO*cw + cO*sw*ci, -sO*sw + cO*cw*ci, -cO * si},
+		{sw * si, cw * si, ci},
+	}
+}
+
+func (m rotation64) apply(v [3]float64) mgl32.Vec3 {
+	var result mgl32.Vec3
+	for i := range m {
+		result[i] = float32(m[i][0]*v[0] + m[i][1]*v[1] + m[i][2]*v[2])
+	}
+	return result
+}
+
+// Angle from a to b in the plane with the normal n, in [0, 2π)
+func signedAngle64(a, b, n [3]float64) float64 {
+	angle := math.Atan2(dot64(cross64(a, b), n)/length64(n), dot64(a, b))
+	return normalizeAngle64(angle)
+}
+
+func normalizeAngle64(angle float64) float64 {
+	angle = math.Mod(angle, 2*math.Pi)
+	if angle < 0 {
+		angle += 2 * math.Pi
+	}
+	return angle
+}
+
+func vec3ToFloat64(v mgl32.Vec3) [3]float64 {
+	return [3]float64{float64(v[0]), float64(v[1]), float64(v[2])}
+}
+
+func dot64(a, b [3]float64) float64 {
+	return a[0]*b[0] + a[1]*b[1] + a[2]*b[2]
+}
+
+func cross64(a, b [3]float64) [3]float64 {
+	return [3]float64{
+		a[1]*b[2] - a[2]*b[1],
+		a[2]*b[0] - a[0]*b[2],
+		a[0]*b[1] - a[1]*b[0],
+	}
+}
+
+func length64(a [3]float64) float64 {
+	return math.Sqrt(dot64(a, a))
+}
+
+func clamp64(value, min, max float64) float64 {
+	return math.Max(min, math.Min(max, value))
+}
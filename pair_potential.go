@@ -0,0 +1,139 @@
+package go_world
+
+import (
+	"math"
+)
+
+// Central potential between two particles. Evaluate returns the force
+// -dU/dr, positive values repel, and the potential energy U at distance r.
+type PairPotential interface {
+	Evaluate(r float32) (force, energy float32)
+}
+
+// User supplied potential
+type PotentialFunc func(r float32) (force, energy float32)
+
+func (f PotentialFunc) Evaluate(r float32) (float32, float32) {
+	return f(r)
+}
+
+// U = 4ε((σ/r)¹² - (σ/r)⁶)
+type LennardJones struct {
+	Epsilon float32
+	Sigma   float32
+}
+
+func (lj LennardJones) Evaluate(r float32) (float32, float32) {
+	s6 := math.Pow(float64(lj.Sigma/r), 6)
+	s12 := s6 * s6
+	eps := float64(lj.Epsilon)
+	force := 24 * eps * (2*s12 - s6) / float64(r)
+	energy := 4 * eps * (s12 - s6)
+	return float32(force), float32(energy)
+}
+
+// U = D(1 - exp(-a(r - r₀)))² - D, the well has depth D at r₀
+type Morse struct {
+	Depth       float32
+	Width       float32
+	Equilibrium float32
+}
+
+func (m Morse) Evaluate(r float32) (float32, float32) {
+	d := float64(m.Depth)
+	a := float64(m.Width)
+	e := math.Exp(-a * float64(r-m.Equilibrium))
+	force := -2 * d * a * e * (1 - e)
+	energy := d*(1-e)*(1-e) - d
+	return float32(force), float32(energy)
+}
+
+// Screened Coulomb potential U = A exp(-κr) / r
+type Yukawa struct {
+	Strength  float32
+	Screening float32
+}
+
+func (y Yukawa) Evaluate(r float32) (float32, float32) {
+	a := float64(y.Strength)
+	k := float64(y.Screening)
+	rr := float64(r)
+	e := math.Exp(-k * rr)
+	force := a * e * (k*rr + 1) / (rr * rr)
+	energy := a * e / rr
+	return float32(force), float32(energy)
+}
+
+// Applies a pair potential between all particles closer than Cutoff. With
+// Shift the energy is shifted to be zero at the cutoff.
+type PairInteractionHandler struct {
+	Potential PairPotential
+	Cutoff    float32
+	Shift     bool
+	Box       *PeriodicBox
+	// Shared neighbour list, its cutoff must not be smaller than Cutoff.
+	// Without one the handler keeps its own with a skin of 20% of Cutoff.
+	Neighbours *NeighbourList
+
+	energy        float32
+	ownNeighbours *NeighbourList
+}
+
+func NewPairInteractionHandler(potential PairPotential, cutoff float32) *PairInteractionHandler {
+	return &PairInteractionHandler{Potential: potential, Cutoff: cutoff}
+}
+
+func (h *PairInteractionHandler) SetPeriodicBox(box *PeriodicBox) {
+	h.Box = box
+}
+
+// Total potential energy of the last Apply
+func (h *PairInteractionHandler) PotentialEnergy() float32 {
+	return h.energy
+}
+
+func (h *PairInteractionHandler) Apply(particles []*Particle, time_delta float32) {
+	h.energy = 0
+	if h.Cutoff <= 0 {
+		return
+	}
+
+	var shift float32
+	if h.Shift {
+		_, shift = h.Potential.Evaluate(h.Cutoff)
+	}
+
+	neighbours := h.neighbourList()
+	neighbours.SetPeriodicBox(h.Box)
+	neighbours.Update(particles)
+	neighbours.ForEachPair(func(a, b *Particle) {
+		h.interact(a, b, shift, time_delta)
+	})
+}
+
+func (h *PairInteractionHandler) neighbourList() *NeighbourList {
+	if h.Neighbours != nil {
+		return h.Neighbours
+	}
+	if h.ownNeighbours == nil || h.ownNeighbours.Cutoff != h.Cutoff {
+		h.ownNeighbours = NewNeighbourList(h.Cutoff, 0.2*h.Cutoff)
+	}
+	return h.ownNeighbours
+}
+
+func (h *PairInteractionHandler) interact(a, b *Particle, shift, time_delta float32) {
+	offset := h.Box.Displacement(a.Position(), b.Position())
+	r := offset.Len()
+	if r >= h.Cutoff || r == 0 {
+		return
+	}
+	force, energy := h.Potential.Evaluate(r)
+	h.energy += energy - shift
+
+	// Repulsive forces push a away from b
+	direction := offset.Mul(force * time_delta / r)
+	dva := direction.Mul(-1 / a.Mass())
+	dvb := direction.Mul(1 / b.Mass())
+	a.ApplyForce(dva[0], dva[1], dva[2])
+	b.ApplyForce(dvb[0], dvb[1], dvb[2])
+}
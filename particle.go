@@ -2,6 +2,7 @@ package go_world
 
 import (
 	"github.com/go-gl/mathgl/mgl32"
+	"math"
 )
 
 type Particle struct {
@@ -9,12 +10,17 @@ type Particle struct {
 	velocity mgl32.Vec3
 	mass     float32
 	radius   float32
+	maxSpeed float32
+	trail    *Trail
 	scene    *Scene
+	pinned   bool
+	path     func(time float32) mgl32.Vec3
 }
 
 func NewParticle(scene *Scene) *Particle {
 	particle := new(Particle)
 	particle.radius = 1
+	particle.mass = 1
 	particle.scene = scene
 	particle.createObject()
 
@@ -28,6 +34,16 @@ func (p *Particle) SetVelocity(x, y, z float32) *Particle{
     return p
 }
 
+// Upper bound for the speed of this particle, 0 disables the limit
+func (p *Particle) SetMaxSpeed(maxSpeed float32) *Particle {
+	p.maxSpeed = maxSpeed
+	return p
+}
+
+func (p *Particle) MaxSpeed() float32 {
+	return p.maxSpeed
+}
+
 func (p *Particle) Velocity() mgl32.Vec3 {
 	return p.velocity
 }
@@ -47,9 +63,10 @@ func (p Particle) Radius() float32 {
 	return p.radius
 }
 
+// Resizes the existing object, its position and place in the scene are kept
 func (p *Particle) SetRadius(radius float32) *Particle {
 	p.radius = radius
-	p.createObject()
+	p.object.SetGeometry(createCircleGeometry(60, p.radius))
 	return p
 }
 
@@ -63,7 +80,70 @@ func (p *Particle) SetPosition(x, y, z float32) *Particle{
 }
 
 func (p *Particle) Mass() float32 {
-	return 1
+	return p.mass
+}
+
+func (p *Particle) SetMass(mass float32) *Particle {
+	p.mass = mass
+	return p
+}
+
+// Fixes the particle at its current position. Forces no longer move it, in
+// collisions and constraints it acts as a body of infinite mass.
+func (p *Particle) Pin() *Particle {
+	p.pinned = true
+	p.velocity = mgl32.Vec3{}
+	return p
+}
+
+func (p *Particle) Unpin() *Particle {
+	p.pinned = false
+	return p
+}
+
+func (p *Particle) Pinned() bool {
+	return p.pinned
+}
+
+// Moves the particle along path, a function of the simulation time, instead
+// of integrating forces. Like a pinned particle it has infinite mass for
+// collisions and constraints, its velocity follows the path. nil returns the
+// particle to normal simulation.
+func (p *Particle) SetKinematicPath(path func(time float32) mgl32.Vec3) *Particle {
+	p.path = path
+	return p
+}
+
+func (p *Particle) Kinematic() bool {
+	return p.path != nil
+}
+
+// 0 for pinned and kinematic particles, which are not moved by forces
+func (p *Particle) InverseMass() float32 {
+	if p.pinned || p.path != nil {
+		return 0
+	}
+	return 1 / p.mass
+}
+
+// Starts drawing the last length positions of the particle, sampled every
+// interval seconds.
+func (p *Particle) EnableTrail(length int, interval float32) *Particle {
+	p.DisableTrail()
+	p.trail = NewTrail(p.scene, length, interval)
+	return p
+}
+
+func (p *Particle) DisableTrail() *Particle {
+	if p.trail != nil {
+		p.trail.remove()
+		p.trail = nil
+	}
+	return p
+}
+
+func (p *Particle) Trail() *Trail {
+	return p.trail
 }
 
 func (p *Particle) createObject() {
@@ -81,6 +161,16 @@ type ParticleSystem struct {
 	constraints        []Constraint
 	collisionHandler   CollisionHandler
 	gravitationHandler GravitationHandler
+	interactions       []InteractionHandler
+	emitters           []*Emitter
+	thermostat         Thermostat
+	maxSpeed           float32
+	damping            float32
+	box                *PeriodicBox
+	time               float32
+	index              *spatialGrid
+	indexValid         bool
+	indexCellSize      float32
 	scene              *Scene
 }
 
@@ -94,6 +184,7 @@ func NewParticleSystem(scene *Scene) *ParticleSystem {
 func (particleSystem *ParticleSystem) NewParticle() *Particle {
 	particle := NewParticle(particleSystem.scene)
 	particleSystem.particles = append(particleSystem.particles, particle)
+	particleSystem.InvalidateSpatialIndex()
 
 	return particle
 }
@@ -104,7 +195,9 @@ func (ps *ParticleSystem) RemoveParticle(particle *Particle) {
 			first := ps.particles[0:i]
 			second := ps.particles[i+1:]
 			ps.scene.RemoveObject(item.object)
+			item.DisableTrail()
 			ps.particles = append(first, second...)
+			ps.InvalidateSpatialIndex()
 			return
 		}
 	}
@@ -130,12 +223,70 @@ func (ps *ParticleSystem) SetGravitationHandler(gh GravitationHandler) {
 	ps.gravitationHandler = gh
 }
 
+func (ps *ParticleSystem) AddInteractionHandler(ih InteractionHandler) {
+	ps.interactions = append(ps.interactions, ih)
+}
+
+func (ps *ParticleSystem) SetThermostat(t Thermostat) {
+	ps.thermostat = t
+}
+
+// Upper bound for the speed of all particles, 0 disables the limit
+func (ps *ParticleSystem) SetMaxSpeed(maxSpeed float32) {
+	ps.maxSpeed = maxSpeed
+}
+
+// Fraction of the velocity lost per second, applied as exp(-damping * dt)
+func (ps *ParticleSystem) SetLinearDamping(damping float32) {
+	ps.damping = damping
+}
+
+// Makes the space periodic, nil restores open boundaries. The box is passed
+// on to all handlers implementing PeriodicHandler, including ones added
+// later. While the system has a box it replaces boxes set on the handlers
+// directly, without one the handlers keep their own.
+func (ps *ParticleSystem) SetPeriodicBox(box *PeriodicBox) {
+	ps.box = box
+	ps.sharePeriodicBox()
+}
+
+func (ps *ParticleSystem) PeriodicBox() *PeriodicBox {
+	return ps.box
+}
+
+func (ps *ParticleSystem) AddEmitter(e *Emitter) {
+	ps.emitters = append(ps.emitters, e)
+}
+
+// Simulation time, the sum of all time deltas passed to Update
+func (ps *ParticleSystem) Time() float32 {
+	return ps.time
+}
+
 func (ps *ParticleSystem) Update(time_delta float32) {
+	ps.time += time_delta
+	if ps.box != nil {
+		ps.sharePeriodicBox()
+	}
+	ps.emit(time_delta)
 	ps.applyForces(time_delta)
 	ps.applyGravitation(time_delta)
+	ps.applyInteractions(time_delta)
+	ps.limitVelocities(time_delta)
+	ps.holdFixed(time_delta)
 	ps.animate(time_delta)
 	ps.handleCollisions()
+	// Separating collisions can push particles out of the box again
+	ps.wrapPositions()
 	ps.applyConstraints(time_delta)
+	ps.updateTrails(time_delta)
+	ps.InvalidateSpatialIndex()
+}
+
+func (ps *ParticleSystem) emit(time_delta float32) {
+	for _, e := range ps.emitters {
+		e.emit(ps, time_delta)
+	}
 }
 
 func (ps *ParticleSystem) applyForces(time_delta float32) {
@@ -144,6 +295,11 @@ func (ps *ParticleSystem) applyForces(time_delta float32) {
 			ff.Apply(p, time_delta)
 		}
 	}
+	for _, ff := range ps.forceFields {
+		if td, ok := ff.(TimeDependent); ok {
+			td.Advance(time_delta)
+		}
+	}
 }
 
 func (ps *ParticleSystem) applyGravitation(time_delta float32) {
@@ -152,6 +308,54 @@ func (ps *ParticleSystem) applyGravitation(time_delta float32) {
 	}
 }
 
+func (ps *ParticleSystem) applyInteractions(time_delta float32) {
+	for _, ih := range ps.interactions {
+		ih.Apply(ps.particles, time_delta)
+	}
+}
+
+func (ps *ParticleSystem) limitVelocities(time_delta float32) {
+	if ps.thermostat != nil {
+		ps.thermostat.Apply(ps.freeParticles(), time_delta)
+	}
+
+	damping := float32(math.Exp(float64(-ps.damping * time_delta)))
+	for _, p := range ps.particles {
+		if ps.damping != 0 {
+			p.velocity = p.velocity.Mul(damping)
+		}
+		p.velocity = limitLength(p.velocity, ps.maxSpeed)
+		p.velocity = limitLength(p.velocity, p.maxSpeed)
+	}
+}
+
+// Pinned and kinematic particles ignore what forces did to their velocity.
+// Kinematic particles move with the velocity of their path over this step.
+func (ps *ParticleSystem) holdFixed(time_delta float32) {
+	for _, p := range ps.particles {
+		switch {
+		case p.path != nil:
+			if time_delta > 0 {
+				v := p.path(ps.time).Sub(p.path(ps.time - time_delta)).Mul(1 / time_delta)
+				p.velocity = v
+			}
+		case p.pinned:
+			p.velocity = mgl32.Vec3{}
+		}
+	}
+}
+
+// Particles that are neither pinned nor kinematic
+func (ps *ParticleSystem) freeParticles() []*Particle {
+	free := make([]*Particle, 0, len(ps.particles))
+	for _, p := range ps.particles {
+		if !p.pinned && p.path == nil {
+			free = append(free, p)
+		}
+	}
+	return free
+}
+
 func (ps *ParticleSystem) applyConstraints(time_delta float32) {
 	for _, p := range ps.particles {
 		for _, c := range ps.constraints {
@@ -168,6 +372,12 @@ func (particleSystem *ParticleSystem) handleCollisions() {
 
 func (particleSystem *ParticleSystem) animate(time_delta float32) {
 	for _, particle := range particleSystem.particles {
+		if particle.path != nil {
+			// Following the path exactly keeps errors from accumulating
+			position := particle.path(particleSystem.time)
+			particle.SetPosition(position[0], position[1], position[2])
+			continue
+		}
 
 		particle.object.position[0] += (float32)(particle.velocity[0] * time_delta)
 		particle.object.position[1] += (float32)(particle.velocity[1] * time_delta)
@@ -175,10 +385,55 @@ func (particleSystem *ParticleSystem) animate(time_delta float32) {
 	}
 }
 
+// Handlers may have been added after the box was set
+func (ps *ParticleSystem) sharePeriodicBox() {
+	handlers := []interface{}{ps.collisionHandler, ps.gravitationHandler}
+	for _, ih := range ps.interactions {
+		handlers = append(handlers, ih)
+	}
+	for _, ff := range ps.forceFields {
+		handlers = append(handlers, ff)
+	}
+	for _, h := range handlers {
+		if ph, ok := h.(PeriodicHandler); ok {
+			ph.SetPeriodicBox(ps.box)
+		}
+	}
+}
+
+func (ps *ParticleSystem) wrapPositions() {
+	if ps.box == nil {
+		return
+	}
+	for _, p := range ps.particles {
+		if position, wrapped := ps.box.Wrap(p.Position()); wrapped {
+			p.SetPosition(position[0], position[1], position[2])
+			// Keeps the trail from streaking across the box
+			if p.trail != nil {
+				p.trail.Clear()
+			}
+		}
+	}
+}
+
+func (ps *ParticleSystem) updateTrails(time_delta float32) {
+	for _, p := range ps.particles {
+		if p.trail != nil {
+			p.trail.update(p.Position(), time_delta)
+		}
+	}
+}
+
 type ForceField interface {
 	Apply(p *Particle, time_detla float32)
 }
 
+// Force fields that change over time are advanced once per Update, after
+// they were applied to the particles for the current step.
+type TimeDependent interface {
+	Advance(time_delta float32)
+}
+
 type Constraint interface {
 	Apply(p *Particle)
 }
@@ -187,6 +442,16 @@ type CollisionHandler interface {
 	Apply(p []*Particle)
 }
 
+// Rescales velocities to hold the kinetic temperature at a target
+type Thermostat interface {
+	Apply(p []*Particle, time_delta float32)
+}
+
 type GravitationHandler interface {
 	Apply(p []*Particle, time_delta float32)
 }
+
+// Forces that depend on several particles at once, e.g. flocking
+type InteractionHandler interface {
+	Apply(p []*Particle, time_delta float32)
+}
@@ -0,0 +1,111 @@
+package go_world
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"math"
+)
+
+// Box with periodic boundaries. Particles leaving through one face re-enter
+// through the opposite one and interactions use the nearest periodic image
+// of every other particle. Axes where Min equals Max are not periodic.
+// Interaction ranges have to be smaller than half the box size.
+type PeriodicBox struct {
+	Min mgl32.Vec3
+	Max mgl32.Vec3
+}
+
+func NewPeriodicBox(min, max mgl32.Vec3) *PeriodicBox {
+	return &PeriodicBox{Min: min, Max: max}
+}
+
+func (b *PeriodicBox) Size() mgl32.Vec3 {
+	return b.Max.Sub(b.Min)
+}
+
+// Maps position into the box. The second value reports whether the position
+// had to be moved.
+func (b *PeriodicBox) Wrap(position mgl32.Vec3) (mgl32.Vec3, bool) {
+	wrapped := false
+	size := b.Size()
+	for i := 0; i < 3; i++ {
+		if size[i] <= 0 {
+			continue
+		}
+		if position[i] < b.Min[i] || position[i] >= b.Max[i] {
+			offset := position[i] - b.Min[i]
+			offset -= size[i] * float32(math.Floor(float64(offset/size[i])))
+			position[i] = b.Min[i] + offset
+			wrapped = true
+		}
+	}
+	return position, wrapped
+}
+
+// Shortest vector between the periodic images of two points
+func (b *PeriodicBox) MinimumImage(d mgl32.Vec3) mgl32.Vec3 {
+	size := b.Size()
+	for i := 0; i < 3; i++ {
+		if size[i] <= 0 {
+			continue
+		}
+		d[i] -= size[i] * float32(math.Floor(float64(d[i]/size[i])+0.5))
+	}
+	return d
+}
+
+// Vector from from to to, using the minimum image convention unless b is nil
+func (b *PeriodicBox) Displacement(from, to mgl32.Vec3) mgl32.Vec3 {
+	if b == nil {
+		return to.Sub(from)
+	}
+	return b.MinimumImage(to.Sub(from))
+}
+
+// Handlers that take the periodic box of their particle system into account
+type PeriodicHandler interface {
+	SetPeriodicBox(box *PeriodicBox)
+}
+
+// Like forEachNear, also visiting the images of center on the other side of
+// the periodic boundaries it is close to. Every particle is passed to fn
+// once, even when the images overlap because radius exceeds half the box.
+func (g *spatialGrid) forEachNearPeriodic(box *PeriodicBox, center mgl32.Vec3, radius float32, fn func(p *Particle)) {
+	if box == nil {
+		g.forEachNear(center, radius, fn)
+		return
+	}
+
+	size := box.Size()
+	var shifts [3][]float32
+	for i := 0; i < 3; i++ {
+		shifts[i] = []float32{0}
+		if size[i] <= 0 {
+			continue
+		}
+		if center[i]-radius < box.Min[i] {
+			shifts[i] = append(shifts[i], size[i])
+		}
+		if center[i]+radius >= box.Max[i] {
+			shifts[i] = append(shifts[i], -size[i])
+		}
+	}
+
+	if len(shifts[0])*len(shifts[1])*len(shifts[2]) == 1 {
+		g.forEachNear(center, radius, fn)
+		return
+	}
+	visited := make(map[*Particle]bool)
+	once := func(p *Particle) {
+		if !visited[p] {
+			visited[p] = true
+			fn(p)
+		}
+	}
+	for _, x := range shifts[0] {
+		for _, y := range shifts[1] {
+			for _, z := range shifts[2] {
+				g.forEachNear(center.Add(mgl32.Vec3{x, y, z}), radius, once)
+			}
+		}
+	}
+}
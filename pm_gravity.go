@@ -0,0 +1,304 @@
+package go_world
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"math"
+)
+
+// Particle-mesh gravity for large, roughly uniform distributions. Masses are
+// assigned to a grid with cloud-in-cell weights, the Poisson equation is
+// solved with FFTs and the forces are interpolated back to the particles
+// with the same weights.
+//
+// Resolution is the number of grid nodes per axis and has to be a power of
+// two, axes where Min equals Max are flat and get a single node. With
+// Periodic the domain repeats and the mean density is removed, a flat
+// periodic domain solves the two dimensional Poisson equation. Otherwise
+// the boundaries are isolated: the grid is zero padded to twice its size and
+// convolved with the softened 1/r kernel, and particles outside of the
+// domain are clamped to its edge.
+type ParticleMeshGravitationHandler struct {
+	G          float32
+	Min        mgl32.Vec3
+	Max        mgl32.Vec3
+	Resolution int
+	Periodic   bool
+
+	nodes   [3]int
+	padded  [3]int
+	spacing [3]float64
+	greens  []complex128
+	grid    []complex128
+	field   [3][]float64
+	config  pmConfig
+}
+
+type pmConfig struct {
+	g          float32
+	min, max   mgl32.Vec3
+	resolution int
+	periodic   bool
+}
+
+func NewParticleMeshGravitationHandler(g float32, min, max mgl32.Vec3, resolution int) *ParticleMeshGravitationHandler {
+	return &ParticleMeshGravitationHandler{G: g, Min: min, Max: max, Resolution: resolution}
+}
+
+// A periodic box of the particle system becomes the periodic domain, without
+// one the mesh keeps its bounds and is no longer periodic
+func (gh *ParticleMeshGravitationHandler) SetPeriodicBox(box *PeriodicBox) {
+	if box == nil {
+		gh.Periodic = false
+		return
+	}
+	gh.Min = box.Min
+	gh.Max = box.Max
+	gh.Periodic = true
+}
+
+func (gh *ParticleMeshGravitationHandler) Apply(particles []*Particle, time_delta float32) {
+	if !isPowerOfTwo(gh.Resolution) || len(particles) == 0 {
+		return
+	}
+	gh.setup()
+
+	for i := range gh.grid {
+		gh.grid[i] = 0
+	}
+	for _, p := range particles {
+		mass := complex(float64(p.Mass())/gh.cellVolume(), 0)
+		gh.forEachWeight(p.Position(), func(index int, weight float64) {
+			gh.grid[index] += mass * complex(weight, 0)
+		})
+	}
+
+	fft3D(gh.grid, gh.padded, false)
+	for i := range gh.grid {
+		gh.grid[i] *= gh.greens[i]
+	}
+	fft3D(gh.grid, gh.padded, true)
+
+	gh.computeField()
+
+	for _, p := range particles {
+		var acceleration [3]float64
+		gh.forEachWeight(p.Position(), func(index int, weight float64) {
+			for axis := 0; axis < 3; axis++ {
+				acceleration[axis] += gh.field[axis][index] * weight
+			}
+		})
+		p.ApplyForce(
+			float32(acceleration[0])*time_delta,
+			float32(acceleration[1])*time_delta,
+			float32(acceleration[2])*time_delta,
+		)
+	}
+}
+
+func (gh *ParticleMeshGravitationHandler) setup() {
+	config := pmConfig{gh.G, gh.Min, gh.Max, gh.Resolution, gh.Periodic}
+	if gh.greens != nil && config == gh.config {
+		return
+	}
+	gh.config = config
+
+	for axis := 0; axis < 3; axis++ {
+		size := float64(gh.Max[axis] - gh.Min[axis])
+		switch {
+		case size <= 0:
+			gh.nodes[axis] = 1
+			gh.padded[axis] = 1
+			gh.spacing[axis] = 1
+		case gh.Periodic:
+			gh.nodes[axis] = gh.Resolution
+			gh.padded[axis] = gh.Resolution
+			gh.spacing[axis] = size / float64(gh.Resolution)
+		default:
+			gh.nodes[axis] = gh.Resolution
+			gh.padded[axis] = 2 * gh.Resolution
+			gh.spacing[axis] = size / float64(gh.Resolution-1)
+		}
+	}
+
+	total := gh.padded[0] * gh.padded[1] * gh.padded[2]
+	gh.grid = make([]complex128, total)
+	gh.greens = make([]complex128, total)
+	for axis := range gh.field {
+		gh.field[axis] = make([]float64, total)
+	}
+
+	if gh.Periodic {
+		gh.setupPeriodicGreens()
+	} else {
+		gh.setupIsolatedGreens()
+	}
+}
+
+// -4πG/k² with the eigenvalues of the discrete Laplacian, the k = 0 mode is
+// dropped.
+func (gh *ParticleMeshGravitationHandler) setupPeriodicGreens() {
+	g := float64(gh.G)
+	gh.forEachPaddedIndex(func(index int, cell [3]int) {
+		var k2 float64
+		for axis := 0; axis < 3; axis++ {
+			if gh.padded[axis] < 2 {
+				continue
+			}
+			s := 2 * math.Sin(math.Pi*float64(cell[axis])/float64(gh.padded[axis])) / gh.spacing[axis]
+			k2 += s * s
+		}
+		if k2 == 0 {
+			gh.greens[index] = 0
+		} else {
+			gh.greens[index] = complex(-4*math.Pi*g/k2, 0)
+		}
+	})
+}
+
+// Transformed -G/r kernel on the padded grid, softened by half a cell
+func (gh *ParticleMeshGravitationHandler) setupIsolatedGreens() {
+	g := float64(gh.G)
+	softening := math.Inf(1)
+	for axis := 0; axis < 3; axis++ {
+		if gh.nodes[axis] > 1 {
+			softening = math.Min(softening, gh.spacing[axis]/2)
+		}
+	}
+	if math.IsInf(softening, 1) {
+		softening = 1
+	}
+
+	gh.forEachPaddedIndex(func(index int, cell [3]int) {
+		r2 := softening * softening
+		for axis := 0; axis < 3; axis++ {
+			// Distances wrap around the padded grid
+			offset := cell[axis]
+			if offset > gh.padded[axis]/2 {
+				offset -= gh.padded[axis]
+			}
+			d := float64(offset) * gh.spacing[axis]
+			r2 += d * d
+		}
+		gh.greens[index] = complex(-g/math.Sqrt(r2), 0)
+	})
+	fft3D(gh.greens, gh.padded, false)
+}
+
+// Assignments are masses, not densities, for the isolated kernel
+func (gh *ParticleMeshGravitationHandler) cellVolume() float64 {
+	if !gh.Periodic {
+		return 1
+	}
+	volume := 1.0
+	for axis := 0; axis < 3; axis++ {
+		if gh.nodes[axis] > 1 {
+			volume *= gh.spacing[axis]
+		}
+	}
+	return volume
+}
+
+// Acceleration -∇φ at every node by central differences, one sided at the
+// edges of an isolated grid.
+func (gh *ParticleMeshGravitationHandler) computeField() {
+	strides := [3]int{gh.padded[1] * gh.padded[2], gh.padded[2], 1}
+	gh.forEachNode(func(index int, cell [3]int) {
+		for axis := 0; axis < 3; axis++ {
+			n := gh.nodes[axis]
+			if n < 2 {
+				gh.field[axis][index] = 0
+				continue
+			}
+			prev, next := cell[axis]-1, cell[axis]+1
+			if gh.Periodic {
+				prev = (prev + n) % n
+				next = next % n
+			} else {
+				prev = maxInt(prev, 0)
+				if next > n-1 {
+					next = n - 1
+				}
+			}
+			phiPrev := real(gh.grid[index+(prev-cell[axis])*strides[axis]])
+			phiNext := real(gh.grid[index+(next-cell[axis])*strides[axis]])
+			distance := float64(next-prev) * gh.spacing[axis]
+			if gh.Periodic {
+				distance = 2 * gh.spacing[axis]
+			}
+			gh.field[axis][index] = -(phiNext - phiPrev) / distance
+		}
+	})
+}
+
+// Calls fn with the padded grid index and cloud-in-cell weight of the up to
+// eight nodes around position.
+func (gh *ParticleMeshGravitationHandler) forEachWeight(position mgl32.Vec3, fn func(index int, weight float64)) {
+	var low, high [3]int
+	var fraction [3]float64
+	for axis := 0; axis < 3; axis++ {
+		n := gh.nodes[axis]
+		if n < 2 {
+			continue
+		}
+		x := float64(position[axis]-gh.Min[axis]) / gh.spacing[axis]
+		if gh.Periodic {
+			x -= float64(n) * math.Floor(x/float64(n))
+		} else {
+			x = math.Max(0, math.Min(x, float64(n-1)))
+		}
+		i := int(math.Floor(x))
+		if i >= n {
+			i = n - 1
+		}
+		low[axis] = i
+		fraction[axis] = x - float64(i)
+		high[axis] = i + 1
+		if high[axis] >= n {
+			if gh.Periodic {
+				high[axis] = 0
+			} else {
+				high[axis] = i
+			}
+		}
+	}
+
+	for corner := 0; corner < 8; corner++ {
+		weight := 1.0
+		var cell [3]int
+		for axis := 0; axis < 3; axis++ {
+			if corner&(1<<uint(axis)) != 0 {
+				cell[axis] = high[axis]
+				weight *= fraction[axis]
+			} else {
+				cell[axis] = low[axis]
+				weight *= 1 - fraction[axis]
+			}
+		}
+		if weight != 0 {
+			fn(gh.index(cell), weight)
+		}
+	}
+}
+
+func (gh *ParticleMeshGravitationHandler) index(cell [3]int) int {
+	return (cell[0]*gh.padded[1]+cell[1])*gh.padded[2] + cell[2]
+}
+
+func (gh *ParticleMeshGravitationHandler) forEachNode(fn func(index int, cell [3]int)) {
+	gh.forEachCell(gh.nodes, fn)
+}
+
+func (gh *ParticleMeshGravitationHandler) forEachPaddedIndex(fn func(index int, cell [3]int)) {
+	gh.forEachCell(gh.padded, fn)
+}
+
+func (gh *ParticleMeshGravitationHandler) forEachCell(dims [3]int, fn func(index int, cell [3]int)) {
+	for x := 0; x < dims[0]; x++ {
+		for y := 0; y < dims[1]; y++ {
+			for z := 0; z < dims[2]; z++ {
+				cell := [3]int{x, y, z}
+				fn(gh.index(cell), cell)
+			}
+		}
+	}
+}
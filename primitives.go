@@ -0,0 +1,442 @@
+package go_world
+
+import (
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+	"math"
+)
+
+// Collects indexed triangles with normals and texture coordinates
+type meshBuilder struct {
+	positions []mgl32.Vec3
+	normals   []mgl32.Vec3
+	uvs       []mgl32.Vec2
+	indices   []uint32
+}
+
+func (b *meshBuilder) vertex(position, normal mgl32.Vec3, uv mgl32.Vec2) uint32 {
+	b.positions = append(b.positions, position)
+	b.normals = append(b.normals, normal)
+	b.uvs = append(b.uvs, uv)
+	return uint32(len(b.positions) - 1)
+}
+
+// Counter clockwise seen from the front. Triangles without area, e.g. at
+// the poles of spheres, are dropped.
+func (b *meshBuilder) triangle(i, j, k uint32) {
+	p := b.positions
+	if p[j].Sub(p[i]).Cross(p[k].Sub(p[i])).Len() < 1e-12 {
+		return
+	}
+	b.indices = append(b.indices, i, j, k)
+}
+
+// Surface sampled at (columns + 1) x (rows + 1) points of the unit square,
+// surface returns position and normal at u, v. The front faces the
+// direction of dP/du x dP/dv. There is at least one column and row.
+func (b *meshBuilder) grid(columns, rows int, surface func(u, v float32) (mgl32.Vec3, mgl32.Vec3)) {
+	if columns < 1 {
+		columns = 1
+	}
+	if rows < 1 {
+		rows = 1
+	}
+	first := uint32(len(b.positions))
+	for j := 0; j <= rows; j++ {
+		for i := 0; i <= columns; i++ {
+			u := float32(i) / float32(columns)
+			v := float32(j) / float32(rows)
+			position, normal := surface(u, v)
+			b.vertex(position, normal, mgl32.Vec2{u, v})
+		}
+	}
+	stride := uint32(columns + 1)
+	for j := 0; j < rows; j++ {
+		for i := 0; i < columns; i++ {
+			a := first + uint32(j)*stride + uint32(i)
+			c := a + stride
+			b.triangle(a, a+1, c+1)
+			b.triangle(a, c+1, c)
+		}
+	}
+}
+
+// Convex polygon in a plane, triangulated from its first point. Texture
+// coordinates map the bounding rectangle of the xy coordinates to [0, 1]².
+func (b *meshBuilder) fan(outline []mgl32.Vec3, normal mgl32.Vec3) {
+	if len(outline) < 3 {
+		return
+	}
+	min, max := outline[0], outline[0]
+	for _, p := range outline {
+		for axis := 0; axis < 2; axis++ {
+			min[axis] = float32(math.Min(float64(min[axis]), float64(p[axis])))
+			max[axis] = float32(math.Max(float64(max[axis]), float64(p[axis])))
+		}
+	}
+	first := uint32(len(b.positions))
+	for _, p := range outline {
+		b.vertex(p, normal, rectangleUV(p, min, max))
+	}
+	for i := 1; i+1 < len(outline); i++ {
+		b.triangle(first, first+uint32(i), first+uint32(i+1))
+	}
+}
+
+func rectangleUV(p, min, max mgl32.Vec3) mgl32.Vec2 {
+	var uv mgl32.Vec2
+	for axis := 0; axis < 2; axis++ {
+		if max[axis] > min[axis] {
+			uv[axis] = (p[axis] - min[axis]) / (max[axis] - min[axis])
+		}
+	}
+	return uv
+}
+
+func (b *meshBuilder) geometry() *Geometry {
+	geometry := new(Geometry)
+	geometry.vertices = to_array(b.positions...)
+	geometry.normals = to_array(b.normals...)
+	geometry.SetUVs(b.uvs...)
+	geometry.indices = b.indices
+	geometry.draw_method = gl.TRIANGLES
+	return geometry
+}
+
+// Counts below 3 rings and 4 sectors, which include the seam, are raised
+// to them
+func CreateSphereGeometry(radius float32, rings, sectors int) *Geometry {
+	if rings < 3 {
+		rings = 3
+	}
+	if sectors < 4 {
+		sectors = 4
+	}
+	return createSphereGeometry(radius, float64(rings), float64(sectors))
+}
+
+// Cube with edges of twice half_extent, centred on the origin
+func CreateCubeGeometry(half_extent float32) *Geometry {
+	return createCubeGeometry(half_extent)
+}
+
+func CreateOctahedronGeometry() *Geometry {
+	return createOctahedronGeometry()
+}
+
+// Grid in the xy plane facing +z, centred on the origin
+func CreatePlaneGeometry(width, height float32, columns, rows int) *Geometry {
+	b := new(meshBuilder)
+	b.grid(columns, rows, func(u, v float32) (mgl32.Vec3, mgl32.Vec3) {
+		return mgl32.Vec3{(u - 0.5) * width, (v - 0.5) * height, 0}, mgl32.Vec3{0, 0, 1}
+	})
+	return b.geometry()
+}
+
+// Ring of tube radius around the z axis, radius is measured to the centre
+// of the tube
+func CreateTorusGeometry(radius, tube float32, radialSegments, tubularSegments int) *Geometry {
+	if radialSegments < 3 {
+		radialSegments = 3
+	}
+	if tubularSegments < 3 {
+		tubularSegments = 3
+	}
+	b := new(meshBuilder)
+	b.grid(radialSegments, tubularSegments, func(u, v float32) (mgl32.Vec3, mgl32.Vec3) {
+		theta := float64(u) * 2 * math.Pi
+		phi := float64(v) * 2 * math.Pi
+		normal := mgl32.Vec3{
+			float32(math.Cos(phi) * math.Cos(theta)),
+			float32(math.Cos(phi) * math.Sin(theta)),
+			float32(math.Sin(phi)),
+		}
+		center := mgl32.Vec3{radius * float32(math.Cos(theta)), radius * float32(math.Sin(theta)), 0}
+		return center.Add(normal.Mul(tube)), normal
+	})
+	return b.geometry()
+}
+
+// Closed cylinder along the y axis, centred on the origin
+func CreateCylinderGeometry(radius, height float32, segments int) *Geometry {
+	b := new(meshBuilder)
+	b.frustum(radius, radius, -height/2, height/2, segments, true, true)
+	return b.geometry()
+}
+
+// Cone along the y axis with its tip at height / 2
+func CreateConeGeometry(radius, height float32, segments int) *Geometry {
+	b := new(meshBuilder)
+	b.frustum(radius, 0, -height/2, height/2, segments, true, false)
+	return b.geometry()
+}
+
+// Side of a cut cone around the y axis from bottom to top with optional caps
+func (b *meshBuilder) frustum(bottomRadius, topRadius, bottom, top float32, segments int, bottomCap, topCap bool) {
+	if segments < 3 {
+		segments = 3
+	}
+	slope := (bottomRadius - topRadius) / (top - bottom)
+	b.grid(segments, 1, func(u, v float32) (mgl32.Vec3, mgl32.Vec3) {
+		theta := float64(u) * 2 * math.Pi
+		sin, cos := float32(math.Sin(theta)), float32(math.Cos(theta))
+		r := bottomRadius + (topRadius-bottomRadius)*v
+		normal := mgl32.Vec3{sin, slope, cos}.Normalize()
+		return mgl32.Vec3{r * sin, bottom + (top-bottom)*v, r * cos}, normal
+	})
+	if bottomCap && bottomRadius > 0 {
+		b.fan(circleOutline(bottomRadius, bottom, segments, true), mgl32.Vec3{0, -1, 0})
+	}
+	if topCap && topRadius > 0 {
+		b.fan(circleOutline(topRadius, top, segments, false), mgl32.Vec3{0, 1, 0})
+	}
+}
+
+// Circle in the plane of constant y, counter clockwise seen from +y or from
+// -y if downwards is set
+func circleOutline(radius, y float32, segments int, downwards bool) []mgl32.Vec3 {
+	outline := make([]mgl32.Vec3, segments)
+	for i := range outline {
+		theta := 2 * math.Pi * float64(i) / float64(segments)
+		if downwards {
+			theta = -theta
+		}
+		outline[i] = mgl32.Vec3{radius * float32(math.Sin(theta)), y, radius * float32(math.Cos(theta))}
+	}
+	return outline
+}
+
+// Cylinder of the given length along the y axis closed by two hemispheres,
+// the total height is length + 2 * radius
+func CreateCapsuleGeometry(radius, length float32, segments, rings int) *Geometry {
+	if segments < 3 {
+		segments = 3
+	}
+	if rings < 1 {
+		rings = 1
+	}
+	b := new(meshBuilder)
+	// Rows 0 to rings form the lower hemisphere, the rest the upper one, the
+	// cylinder is the band between the two equators
+	rows := 2*rings + 1
+	b.grid(segments, rows, func(u, v float32) (mgl32.Vec3, mgl32.Vec3) {
+		row := int(math.Floor(float64(v)*float64(rows) + 0.5))
+		var phi float64
+		offset := -length / 2
+		if row <= rings {
+			phi = -math.Pi/2 + math.Pi/2*float64(row)/float64(rings)
+		} else {
+			phi = math.Pi / 2 * float64(row-rings-1) / float64(rings)
+			offset = length / 2
+		}
+		theta := float64(u) * 2 * math.Pi
+		normal := mgl32.Vec3{
+			float32(math.Cos(phi) * math.Sin(theta)),
+			float32(math.Sin(phi)),
+			float32(math.Cos(phi) * math.Cos(theta)),
+		}
+		return normal.Mul(radius).Add(mgl32.Vec3{0, offset, 0}), normal
+	})
+	return b.geometry()
+}
+
+// Sphere from an icosahedron whose triangles are split in four subdivisions
+// times, more even than the UV sphere
+func CreateIcosphereGeometry(radius float32, subdivisions int) *Geometry {
+	t := float32((1 + math.Sqrt(5)) / 2)
+	positions := []mgl32.Vec3{
+		{-1, t, 0}, {1, t, 0}, {-1, -t, 0}, {1, -t, 0},
+		{0, -1, t}, {0, 1, t}, {0, -1, -t}, {0, 1, -t},
+		{t, 0, -1}, {t, 0, 1}, {-t, 0, -1}, {-t, 0, 1},
+	}
+	faces := [][3]uint32{
+		{0, 11, 5}, {0, 5, 1}, {0, 1, 7}, {0, 7, 10}, {0, 10, 11},
+		{1, 5, 9}, {5, 11, 4}, {11, 10, 2}, {10, 7, 6}, {7, 1, 8},
+		{3, 9, 4}, {3, 4, 2}, {3, 2, 6}, {3, 6, 8}, {3, 8, 9},
+		{4, 9, 5}, {2, 4, 11}, {6, 2, 10}, {8, 6, 7}, {9, 8, 1},
+	}
+	return subdividedSphere(positions, faces, radius, subdivisions)
+}
+
+// Sphere from a subdivided octahedron, its vertices include the poles and
+// the points on the axes
+func CreateOctasphereGeometry(radius float32, subdivisions int) *Geometry {
+	positions := []mgl32.Vec3{
+		{0, 1, 0}, {1, 0, 0}, {0, 0, 1}, {-1, 0, 0}, {0, 0, -1}, {0, -1, 0},
+	}
+	faces := [][3]uint32{
+		{0, 2, 1}, {0, 3, 2}, {0, 4, 3}, {0, 1, 4},
+		{5, 1, 2}, {5, 2, 3}, {5, 3, 4}, {5, 4, 1},
+	}
+	return subdividedSphere(positions, faces, radius, subdivisions)
+}
+
+func subdividedSphere(positions []mgl32.Vec3, faces [][3]uint32, radius float32, subdivisions int) *Geometry {
+	for i := range positions {
+		positions[i] = positions[i].Normalize()
+	}
+	for s := 0; s < subdivisions; s++ {
+		midpoints := make(map[[2]uint32]uint32)
+		midpoint := func(a, b uint32) uint32 {
+			key := [2]uint32{a, b}
+			if b < a {
+				key = [2]uint32{b, a}
+			}
+			if index, ok := midpoints[key]; ok {
+				return index
+			}
+			positions = append(positions, positions[a].Add(positions[b]).Normalize())
+			index := uint32(len(positions) - 1)
+			midpoints[key] = index
+			return index
+		}
+		next := make([][3]uint32, 0, len(faces)*4)
+		for _, f := range faces {
+			ab, bc, ca := midpoint(f[0], f[1]), midpoint(f[1], f[2]), midpoint(f[2], f[0])
+			next = append(next, [3]uint32{f[0], ab, ca}, [3]uint32{f[1], bc, ab}, [3]uint32{f[2], ca, bc}, [3]uint32{ab, bc, ca})
+		}
+		faces = next
+	}
+
+	b := new(meshBuilder)
+	for _, p := range positions {
+		// Equirectangular texture coordinates, they wrap around at the seam
+		uv := mgl32.Vec2{
+			float32(0.5 + math.Atan2(float64(p[0]), float64(p[2]))/(2*math.Pi)),
+			float32(0.5 + math.Asin(float64(mgl32.Clamp(p[1], -1, 1)))/math.Pi),
+		}
+		b.vertex(p.Mul(radius), p, uv)
+	}
+
+	// Triangles across the seam get copies of their vertices near u = 0
+	// moved to u = 1, like the separate seam column of the UV sphere
+	wrapped := make(map[uint32]uint32)
+	wrap := func(i uint32) uint32 {
+		if index, ok := wrapped[i]; ok {
+			return index
+		}
+		uv := b.uvs[i].Add(mgl32.Vec2{1, 0})
+		index := b.vertex(b.positions[i], b.normals[i], uv)
+		wrapped[i] = index
+		return index
+	}
+	// The u of a pole is arbitrary, each triangle gets its own copy halfway
+	// between its other corners
+	isPole := func(i uint32) bool {
+		return b.normals[i][1] > 1-1e-6 || b.normals[i][1] < -1+1e-6
+	}
+	for _, f := range faces {
+		min, max := float32(1), float32(0)
+		for _, i := range f {
+			if !isPole(i) {
+				min = float32(math.Min(float64(min), float64(b.uvs[i][0])))
+				max = float32(math.Max(float64(max), float64(b.uvs[i][0])))
+			}
+		}
+		if max-min > 0.5 {
+			for k, i := range f {
+				if !isPole(i) && b.uvs[i][0] < 0.5 {
+					f[k] = wrap(i)
+				}
+			}
+		}
+		for k, i := range f {
+			if isPole(i) {
+				u := (b.uvs[f[(k+1)%3]][0] + b.uvs[f[(k+2)%3]][0]) / 2
+				f[k] = b.vertex(b.positions[i], b.normals[i], mgl32.Vec2{u, b.uvs[i][1]})
+			}
+		}
+		b.triangle(f[0], f[1], f[2])
+	}
+	return b.geometry()
+}
+
+// Arrow from the origin along +y, a cylindrical shaft topped by a cone
+func CreateArrowGeometry(length, shaftRadius, headRadius, headLength float32, segments int) *Geometry {
+	if headLength > length {
+		headLength = length
+	}
+	b := new(meshBuilder)
+	shaft := length - headLength
+	if shaft > 0 {
+		b.frustum(shaftRadius, shaftRadius, 0, shaft, segments, true, false)
+	}
+	b.frustum(headRadius, 0, shaft, length, segments, true, false)
+	return b.geometry()
+}
+
+// Rectangle in the xy plane facing +z, centred on the origin
+func CreateRectangleGeometry(width, height float32) *Geometry {
+	return CreatePlaneGeometry(width, height, 1, 1)
+}
+
+// Rectangle whose corners are quarter circles of the given radius, each
+// made of cornerSegments segments
+func CreateRoundedRectangleGeometry(width, height, radius float32, cornerSegments int) *Geometry {
+	radius = float32(math.Min(float64(radius), math.Min(float64(width), float64(height))/2))
+	if cornerSegments < 1 {
+		cornerSegments = 1
+	}
+	centers := []mgl32.Vec2{
+		{width/2 - radius, height/2 - radius},
+		{-width/2 + radius, height/2 - radius},
+		{-width/2 + radius, -height/2 + radius},
+		{width/2 - radius, -height/2 + radius},
+	}
+	var outline []mgl32.Vec3
+	for corner, center := range centers {
+		for i := 0; i <= cornerSegments; i++ {
+			angle := math.Pi/2*float64(corner) + math.Pi/2*float64(i)/float64(cornerSegments)
+			outline = append(outline, mgl32.Vec3{
+				center[0] + radius*float32(math.Cos(angle)),
+				center[1] + radius*float32(math.Sin(angle)),
+				0,
+			})
+		}
+	}
+	b := new(meshBuilder)
+	b.fan(outline, mgl32.Vec3{0, 0, 1})
+	return b.geometry()
+}
+
+// Polygon with equal sides in the xy plane, the first corner points along +y
+func CreateRegularPolygonGeometry(sides int, radius float32) *Geometry {
+	if sides < 3 {
+		sides = 3
+	}
+	outline := make([]mgl32.Vec3, sides)
+	for i := range outline {
+		angle := math.Pi/2 + 2*math.Pi*float64(i)/float64(sides)
+		outline[i] = mgl32.Vec3{radius * float32(math.Cos(angle)), radius * float32(math.Sin(angle)), 0}
+	}
+	b := new(meshBuilder)
+	b.fan(outline, mgl32.Vec3{0, 0, 1})
+	return b.geometry()
+}
+
+// Annulus in the xy plane
+func CreateRingGeometry(innerRadius, outerRadius float32, segments int) *Geometry {
+	if segments < 3 {
+		segments = 3
+	}
+	return CreateArcGeometry(innerRadius, outerRadius, 0, 2*math.Pi, segments)
+}
+
+// Part of an annulus from startAngle to endAngle in radians, counter
+// clockwise from +x. An inner radius of 0 gives a circular sector.
+func CreateArcGeometry(innerRadius, outerRadius, startAngle, endAngle float32, segments int) *Geometry {
+	b := new(meshBuilder)
+	b.grid(segments, 1, func(u, v float32) (mgl32.Vec3, mgl32.Vec3) {
+		angle := float64(startAngle + (endAngle-startAngle)*u)
+		r := innerRadius + (outerRadius-innerRadius)*v
+		return mgl32.Vec3{r * float32(math.Cos(angle)), r * float32(math.Sin(angle)), 0}, mgl32.Vec3{0, 0, 1}
+	})
+	// Along the angle first and then outwards the grid faces -z for counter
+	// clockwise arcs
+	if endAngle > startAngle {
+		for i := 0; i+2 < len(b.indices); i += 3 {
+			b.indices[i+1], b.indices[i+2] = b.indices[i+2], b.indices[i+1]
+		}
+	}
+	return b.geometry()
+}
@@ -2,7 +2,6 @@ package go_world
 
 import (
     "github.com/go-gl/gl/v4.1-core/gl"
-    "github.com/go-gl/mathgl/mgl32"
 	"github.com/go-gl/glfw/v3.1/glfw"
 )
 
@@ -29,31 +28,24 @@ func (r *Renderer) render(scene *Scene) {
 
 func (r *Renderer) renderObject(object *Object) {
 
-    // TODO: Make naming and logic consistent
-    mat := mgl32.Ident4()
-    rot := mgl32.Ident4()
-    trans := mgl32.Translate3D(
-        object.position[0],
-        object.position[1],
-        object.position[2],
-    )
-    scale := mgl32.Scale3D(
-        object.scale[0],
-        object.scale[1],
-        //object.scale[2], // TODO
-        0.0,
-    )
-
-    rot = mgl32.HomogRotate3D(float32(object.angle), mgl32.Vec3{0, 1, 0})
-    mat = mat.Mul4(scale).Mul4(trans).Mul4(rot)
+    mat := object.ModelMatrix()
 
     // Render calls
     gl.UniformMatrix4fv(object.modelUniform, 1, false, &mat[0])
     gl.BindVertexArray(object.vao)
+    if object.geometry.indexed() && object.ebo != 0 {
+        gl.DrawElements(
+            object.geometry.draw_method,
+            object.geometry.indexCount(),
+            object.indexType,
+            gl.PtrOffset(0),
+        )
+        return
+    }
     gl.DrawArrays( 
         object.geometry.draw_method, 
         0,
-        int32(len(object.geometry.vertices)),
+        object.geometry.vertexCount(),
     )
 
 }
@@ -0,0 +1,649 @@
+package go_world
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// Declarative description of a whole simulation, usually read from a JSON
+// file with LoadScenario. Vectors are arrays of up to three numbers, missing
+// components are zero. Zero values keep the defaults of the created objects.
+type Scenario struct {
+	Window       *WindowSpec       `json:"window"`
+	Camera       *CameraSpec       `json:"camera"`
+	Particles    []ParticleSpec    `json:"particles"`
+	Emitters     []EmitterSpec     `json:"emitters"`
+	ForceFields  []ForceFieldSpec  `json:"force_fields"`
+	Interactions []InteractionSpec `json:"interactions"`
+	Constraints  []ConstraintSpec  `json:"constraints"`
+	Collision    *CollisionSpec    `json:"collision"`
+	Gravitation  *GravitationSpec  `json:"gravitation"`
+	Geometry     []GeometrySpec    `json:"geometry"`
+
+	data []byte
+	// Start offsets of the top level values, one per element for arrays
+	offsets map[string][]int64
+}
+
+type WindowSpec struct {
+	Title  string `json:"title"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+type CameraSpec struct {
+	Eye    mgl32.Vec3 `json:"eye"`
+	Target mgl32.Vec3 `json:"target"`
+	Up     mgl32.Vec3 `json:"up"`
+	// Vertical field of view in degrees
+	FieldOfView float32 `json:"fov"`
+	Near        float32 `json:"near"`
+	Far         float32 `json:"far"`
+}
+
+type ParticleSpec struct {
+	Position mgl32.Vec3 `json:"position"`
+	Velocity mgl32.Vec3 `json:"velocity"`
+	Mass     float32    `json:"mass"`
+	Radius   float32    `json:"radius"`
+	Trail    *TrailSpec `json:"trail"`
+}
+
+type TrailSpec struct {
+	Length   int         `json:"length"`
+	Interval float32     `json:"interval"`
+	Head     *mgl32.Vec4 `json:"head"`
+	Tail     *mgl32.Vec4 `json:"tail"`
+}
+
+type EmitterSpec struct {
+	Position     mgl32.Vec3 `json:"position"`
+	Velocity     mgl32.Vec3 `json:"velocity"`
+	Spread       float32    `json:"spread"`
+	Rate         float32    `json:"rate"`
+	Radius       float32    `json:"radius"`
+	Mass         float32    `json:"mass"`
+	MaxParticles int        `json:"max_particles"`
+}
+
+// One of "uniform" (force), "point" (center, strength) or "drag" (coefficient)
+type ForceFieldSpec struct {
+	Type        string     `json:"type"`
+	Force       mgl32.Vec3 `json:"force"`
+	Center      mgl32.Vec3 `json:"center"`
+	Strength    float32    `json:"strength"`
+	Coefficient float32    `json:"coefficient"`
+}
+
+// Currently only "flock"
+type InteractionSpec struct {
+	Type             string          `json:"type"`
+	PerceptionRadius float32         `json:"perception_radius"`
+	SeparationRadius *float32        `json:"separation_radius"`
+	SeparationWeight *float32        `json:"separation_weight"`
+	AlignmentWeight  *float32        `json:"alignment_weight"`
+	CohesionWeight   *float32        `json:"cohesion_weight"`
+	ObstacleWeight   *float32        `json:"obstacle_weight"`
+	GoalWeight       *float32        `json:"goal_weight"`
+	FieldOfView      *float32        `json:"fov"`
+	MaxSpeed         *float32        `json:"max_speed"`
+	MaxForce         *float32        `json:"max_force"`
+	Goal             *mgl32.Vec3     `json:"goal"`
+	Obstacles        []FlockObstacle `json:"obstacles"`
+}
+
+// Currently only "box"
+type ConstraintSpec struct {
+	Type        string     `json:"type"`
+	Min         mgl32.Vec3 `json:"min"`
+	Max         mgl32.Vec3 `json:"max"`
+	Restitution *float32   `json:"restitution"`
+}
+
+// Currently only "elastic"
+type CollisionSpec struct {
+	Type        string   `json:"type"`
+	Restitution *float32 `json:"restitution"`
+}
+
+// Currently only "newtonian"
+type GravitationSpec struct {
+	Type      string  `json:"type"`
+	G         float32 `json:"g"`
+	Softening float32 `json:"softening"`
+}
+
+// One of "line" (start, end), "line_loop" and "line_strip" (points) or
+// "circle" (radius, segments)
+type GeometrySpec struct {
+	Type     string       `json:"type"`
+	Points   []mgl32.Vec3 `json:"points"`
+	Start    mgl32.Vec3   `json:"start"`
+	End      mgl32.Vec3   `json:"end"`
+	Radius   float32      `json:"radius"`
+	Segments int          `json:"segments"`
+	Position mgl32.Vec3   `json:"position"`
+}
+
+// Error in a scenario file with the position of the offending value. Line
+// is 0 when the position is not known.
+type ScenarioError struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *ScenarioError) Error() string {
+	file := e.File
+	if file == "" {
+		file = "scenario"
+	}
+	if e.Line == 0 {
+		return fmt.Sprintf("%s: %s", file, e.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", file, e.Line, e.Column, e.Message)
+}
+
+// Reads the scenario at path and builds it into world. The returned particle
+// system still has to be updated by the caller.
+func LoadScenario(path string, world *World) (*ParticleSystem, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	scenario, err := ParseScenario(data)
+	if err != nil {
+		if se, ok := err.(*ScenarioError); ok {
+			se.File = path
+		}
+		return nil, err
+	}
+	return scenario.Build(world), nil
+}
+
+func ParseScenario(data []byte) (*Scenario, error) {
+	s := new(Scenario)
+	s.data = data
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(s); err != nil {
+		return nil, s.decodeError(err, decoder.InputOffset())
+	}
+	s.offsets = scanOffsets(data)
+
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Applies window and camera settings to world and creates the particle
+// system and static geometry.
+func (s *Scenario) Build(world *World) *ParticleSystem {
+	s.buildWindow(world)
+	s.buildCamera(world)
+
+	ps := NewParticleSystem(world.Scene)
+	for _, spec := range s.Particles {
+		spec.build(ps)
+	}
+	for _, spec := range s.Emitters {
+		ps.AddEmitter(spec.build())
+	}
+	for _, spec := range s.ForceFields {
+		ps.AddForceField(spec.build())
+	}
+	for _, spec := range s.Interactions {
+		ps.AddInteractionHandler(spec.build())
+	}
+	for _, spec := range s.Constraints {
+		ps.AddConstraint(spec.build())
+	}
+	if s.Collision != nil {
+		ps.SetCollisionHandler(s.Collision.build())
+	}
+	if s.Gravitation != nil {
+		ps.SetGravitationHandler(s.Gravitation.build())
+	}
+	for _, spec := range s.Geometry {
+		object := NewObject(spec.build())
+		object.SetPosition(spec.Position[0], spec.Position[1], spec.Position[2])
+		object.configure(world.program)
+		world.Scene.AddObject(object)
+	}
+	return ps
+}
+
+func (s *Scenario) buildWindow(world *World) {
+	if s.Window == nil || world.window == nil {
+		return
+	}
+	if s.Window.Title != "" {
+		world.window.SetTitle(s.Window.Title)
+	}
+	if s.Window.Width > 0 && s.Window.Height > 0 {
+		world.window.SetSize(s.Window.Width, s.Window.Height)
+		gl.Viewport(0, 0, int32(s.Window.Width), int32(s.Window.Height))
+	}
+}
+
+// Near and far planes with defaults applied, the far plane defaults to a
+// hundred times the near one
+func (c CameraSpec) clipPlanes() (near, far float32) {
+	near, far = c.Near, c.Far
+	if near == 0 {
+		near = 0.1
+	}
+	if far == 0 {
+		far = near * 100
+	}
+	return near, far
+}
+
+func (s *Scenario) buildCamera(world *World) {
+	if s.Camera == nil || world.camera == nil {
+		return
+	}
+	spec := *s.Camera
+	if spec.FieldOfView == 0 {
+		spec.FieldOfView = 45
+	}
+	spec.Near, spec.Far = spec.clipPlanes()
+	if spec.Eye == (mgl32.Vec3{}) {
+		spec.Eye = mgl32.Vec3{0, 0, 3}
+	}
+	if spec.Up == (mgl32.Vec3{}) {
+		spec.Up = mgl32.Vec3{0, 1, 0}
+	}
+	aspect := float32(windowWidth) / windowHeight
+	if s.Window != nil && s.Window.Width > 0 && s.Window.Height > 0 {
+		aspect = float32(s.Window.Width) / float32(s.Window.Height)
+	}
+	world.camera.
+		SetPerspective(mgl32.DegToRad(spec.FieldOfView), aspect, spec.Near, spec.Far).
+		LookAt(spec.Eye, spec.Target, spec.Up)
+}
+
+func (spec ParticleSpec) build(ps *ParticleSystem) *Particle {
+	p := ps.NewParticle()
+	if spec.Radius > 0 {
+		p.SetRadius(spec.Radius)
+	}
+	if spec.Mass > 0 {
+		p.SetMass(spec.Mass)
+	}
+	p.SetPosition(spec.Position[0], spec.Position[1], spec.Position[2])
+	p.SetVelocity(spec.Velocity[0], spec.Velocity[1], spec.Velocity[2])
+
+	if spec.Trail != nil {
+		p.EnableTrail(spec.Trail.Length, spec.Trail.Interval)
+		trail := p.Trail()
+		head, tail := trail.head, trail.tail
+		if spec.Trail.Head != nil {
+			head = *spec.Trail.Head
+		}
+		if spec.Trail.Tail != nil {
+			tail = *spec.Trail.Tail
+		}
+		trail.SetColors(head, tail)
+	}
+	return p
+}
+
+func (spec EmitterSpec) build() *Emitter {
+	emitter := NewEmitter(spec.Position, spec.Rate)
+	emitter.Velocity = spec.Velocity
+	emitter.Spread = spec.Spread
+	emitter.MaxParticles = spec.MaxParticles
+	if spec.Radius > 0 {
+		emitter.Radius = spec.Radius
+	}
+	if spec.Mass > 0 {
+		emitter.Mass = spec.Mass
+	}
+	return emitter
+}
+
+func (spec ForceFieldSpec) build() ForceField {
+	switch spec.Type {
+	case "uniform":
+		return NewUniformForceField(spec.Force)
+	case "point":
+		return NewPointForceField(spec.Center, spec.Strength)
+	case "drag":
+		return NewDragForceField(spec.Coefficient)
+	}
+	return nil
+}
+
+func (spec InteractionSpec) build() InteractionHandler {
+	flock := NewFlock(spec.PerceptionRadius)
+	setIfPresent(&flock.SeparationRadius, spec.SeparationRadius)
+	setIfPresent(&flock.SeparationWeight, spec.SeparationWeight)
+	setIfPresent(&flock.AlignmentWeight, spec.AlignmentWeight)
+	setIfPresent(&flock.CohesionWeight, spec.CohesionWeight)
+	setIfPresent(&flock.ObstacleWeight, spec.ObstacleWeight)
+	setIfPresent(&flock.GoalWeight, spec.GoalWeight)
+	setIfPresent(&flock.MaxSpeed, spec.MaxSpeed)
+	setIfPresent(&flock.MaxForce, spec.MaxForce)
+	if spec.FieldOfView != nil {
+		flock.FieldOfView = mgl32.DegToRad(*spec.FieldOfView)
+	}
+	if spec.Goal != nil {
+		flock.SetGoal(*spec.Goal)
+	}
+	for _, o := range spec.Obstacles {
+		flock.AddObstacle(o.Center, o.Radius)
+	}
+	return flock
+}
+
+func (spec ConstraintSpec) build() Constraint {
+	box := NewBoxConstraint(spec.Min, spec.Max)
+	setIfPresent(&box.Restitution, spec.Restitution)
+	return box
+}
+
+func (spec CollisionSpec) build() CollisionHandler {
+	handler := NewElasticCollisionHandler(1)
+	setIfPresent(&handler.Restitution, spec.Restitution)
+	return handler
+}
+
+func (spec GravitationSpec) build() GravitationHandler {
+	handler := NewNewtonianGravitationHandler(spec.G)
+	handler.Softening = spec.Softening
+	return handler
+}
+
+func (spec GeometrySpec) build() *Geometry {
+	switch spec.Type {
+	case "line":
+		return CreateLineGeometry(spec.Start, spec.End)
+	case "line_loop":
+		return CreateLineLoopGeometry(spec.Points...)
+	case "line_strip":
+		return CreateLineStripGeometry(spec.Points...)
+	case "circle":
+		segments := spec.Segments
+		if segments == 0 {
+			segments = 60
+		}
+		return CreateCircleGeometry(segments, spec.Radius)
+	}
+	return nil
+}
+
+func setIfPresent(target *float32, value *float32) {
+	if value != nil {
+		*target = *value
+	}
+}
+
+func (s *Scenario) validate() error {
+	if w := s.Window; w != nil {
+		if w.Width < 0 || w.Height < 0 {
+			return s.errorf("window", -1, "window size must not be negative")
+		}
+	}
+	if c := s.Camera; c != nil {
+		if c.FieldOfView < 0 || c.FieldOfView >= 180 {
+			return s.errorf("camera", -1, "fov must be between 0 and 180 degrees")
+		}
+		near, far := c.clipPlanes()
+		if c.Near < 0 || c.Far < 0 || far <= near {
+			return s.errorf("camera", -1, "near and far must be positive with near < far")
+		}
+		if c.Eye != (mgl32.Vec3{}) && c.Eye == c.Target {
+			return s.errorf("camera", -1, "eye and target must differ")
+		}
+	}
+	for i, p := range s.Particles {
+		if p.Mass < 0 || p.Radius < 0 {
+			return s.errorf("particles", i, "mass and radius must not be negative")
+		}
+		if t := p.Trail; t != nil && (t.Length <= 0 || t.Interval < 0) {
+			return s.errorf("particles", i, "trail needs a positive length and a non negative interval")
+		}
+	}
+	for i, e := range s.Emitters {
+		if e.Rate < 0 || e.Spread < 0 || e.Mass < 0 || e.Radius < 0 || e.MaxParticles < 0 {
+			return s.errorf("emitters", i, "rate, spread, mass, radius and max_particles must not be negative")
+		}
+	}
+	for i, ff := range s.ForceFields {
+		switch ff.Type {
+		case "uniform", "drag":
+		case "point":
+			if ff.Strength == 0 {
+				return s.errorf("force_fields", i, "point force field needs a strength")
+			}
+		default:
+			return s.errorf("force_fields", i, "unknown force field type %q", ff.Type)
+		}
+	}
+	for i, ih := range s.Interactions {
+		if ih.Type != "flock" {
+			return s.errorf("interactions", i, "unknown interaction type %q", ih.Type)
+		}
+		if ih.PerceptionRadius <= 0 {
+			return s.errorf("interactions", i, "flock needs a positive perception_radius")
+		}
+	}
+	for i, c := range s.Constraints {
+		if c.Type != "box" {
+			return s.errorf("constraints", i, "unknown constraint type %q", c.Type)
+		}
+		for axis := 0; axis < 3; axis++ {
+			if c.Min[axis] > c.Max[axis] {
+				return s.errorf("constraints", i, "box min must not exceed max")
+			}
+		}
+	}
+	if c := s.Collision; c != nil {
+		if c.Type != "elastic" {
+			return s.errorf("collision", -1, "unknown collision type %q", c.Type)
+		}
+		if r := c.Restitution; r != nil && (*r < 0 || *r > 1) {
+			return s.errorf("collision", -1, "restitution must be between 0 and 1")
+		}
+	}
+	if g := s.Gravitation; g != nil {
+		if g.Type != "newtonian" {
+			return s.errorf("gravitation", -1, "unknown gravitation type %q", g.Type)
+		}
+		if g.G == 0 || g.Softening < 0 {
+			return s.errorf("gravitation", -1, "gravitation needs a non zero g and a non negative softening")
+		}
+	}
+	for i, g := range s.Geometry {
+		switch g.Type {
+		case "line":
+		case "line_loop", "line_strip":
+			if len(g.Points) < 2 {
+				return s.errorf("geometry", i, "%s needs at least two points", g.Type)
+			}
+		case "circle":
+			if g.Radius <= 0 || (g.Segments != 0 && g.Segments < 3) {
+				return s.errorf("geometry", i, "circle needs a positive radius and at least three segments")
+			}
+		default:
+			return s.errorf("geometry", i, "unknown geometry type %q", g.Type)
+		}
+	}
+	return nil
+}
+
+// Error for the value at key, index is the element for arrays and -1 for
+// single values.
+func (s *Scenario) errorf(key string, index int, format string, args ...interface{}) error {
+	path := key
+	if index >= 0 {
+		path = fmt.Sprintf("%s[%d]", key, index)
+	} else {
+		index = 0
+	}
+
+	var offset int64
+	if offsets := s.offsets[key]; index < len(offsets) {
+		offset = offsets[index]
+	}
+	line, column := lineColumn(s.data, offset)
+	return &ScenarioError{
+		Line:    line,
+		Column:  column,
+		Message: path + ": " + fmt.Sprintf(format, args...),
+	}
+}
+
+func (s *Scenario) decodeError(err error, offset int64) error {
+	message := err.Error()
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		offset = e.Offset
+	case *json.UnmarshalTypeError:
+		offset = e.Offset
+		message = fmt.Sprintf("%s: cannot use %s as %s", e.Field, e.Value, e.Type)
+	default:
+		// Unknown fields come as plain errors, the decoder has read the
+		// whole document by then
+		const prefix = "json: unknown field "
+		if !strings.HasPrefix(message, prefix) {
+			break
+		}
+		name, unquoteErr := strconv.Unquote(strings.TrimPrefix(message, prefix))
+		if unquoteErr != nil {
+			break
+		}
+		message = fmt.Sprintf("unknown field %q", name)
+		// A name used as key more than once may be valid elsewhere, then
+		// the position is left out rather than guessed
+		offsets := keyOffsets(s.data, name)
+		if len(offsets) != 1 {
+			return &ScenarioError{Message: message}
+		}
+		offset = offsets[0]
+	}
+	line, column := lineColumn(s.data, offset)
+	return &ScenarioError{Line: line, Column: column, Message: message}
+}
+
+// Records where the top level values of a decoded document start. The
+// document is known to be valid at this point.
+func scanOffsets(data []byte) map[string][]int64 {
+	offsets := make(map[string][]int64)
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if _, err := decoder.Token(); err != nil {
+		return offsets
+	}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return offsets
+		}
+		key, _ := token.(string)
+		start := skipSeparators(data, decoder.InputOffset())
+
+		var raw json.RawMessage
+		if start < int64(len(data)) && data[start] == '[' {
+			decoder.Token()
+			for decoder.More() {
+				offsets[key] = append(offsets[key], skipSeparators(data, decoder.InputOffset()))
+				if decoder.Decode(&raw) != nil {
+					return offsets
+				}
+			}
+			decoder.Token()
+		} else {
+			offsets[key] = []int64{start}
+			if decoder.Decode(&raw) != nil {
+				return offsets
+			}
+		}
+	}
+	return offsets
+}
+
+// Positions of all object keys called name, at any depth
+func keyOffsets(data []byte, name string) []int64 {
+	type container struct {
+		object  bool
+		wantKey bool
+	}
+	var offsets []int64
+	var stack []container
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for {
+		before := decoder.InputOffset()
+		token, err := decoder.Token()
+		if err != nil {
+			return offsets
+		}
+		var top *container
+		if len(stack) > 0 {
+			top = &stack[len(stack)-1]
+		}
+		if top != nil && top.object && top.wantKey {
+			if key, ok := token.(string); ok {
+				if key == name {
+					offsets = append(offsets, skipSeparators(data, before))
+				}
+				top.wantKey = false
+				continue
+			}
+		}
+		switch token {
+		case json.Delim('}'), json.Delim(']'):
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		// A value, the next token of the enclosing object is a key again
+		if top != nil && top.object {
+			top.wantKey = true
+		}
+		switch token {
+		case json.Delim('{'):
+			stack = append(stack, container{object: true, wantKey: true})
+		case json.Delim('['):
+			stack = append(stack, container{})
+		}
+	}
+}
+
+// The decoder reports the offset after the previous token, the value starts
+// after the following whitespace, comma or colon.
+func skipSeparators(data []byte, offset int64) int64 {
+	for offset < int64(len(data)) {
+		switch data[offset] {
+		case ' ', '\t', '\r', '\n', ',', ':':
+			offset++
+		default:
+			return offset
+		}
+	}
+	return offset
+}
+
+func lineColumn(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	line, column := 1, 1
+	for _, c := range data[:offset] {
+		if c == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+	return line, column
+}
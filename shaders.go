@@ -30,21 +30,34 @@ func compileShader(source string, shaderType uint32) (uint32, error) {
 
 var vertexShader = `
 #version 330
-in vec2 position;
+in vec3 position;
+in vec3 normal;
+in vec2 uv;
+in vec4 color;
 uniform mat4 model;
 uniform mat4 camera;
 uniform mat4 projection;
+out vec3 fragmentNormal;
+out vec2 fragmentUV;
+out vec4 fragmentColor;
 
 void main() {
-	gl_Position = projection * camera  * model * vec4(position, 0.0, 1);
+	gl_Position = projection * camera  * model * vec4(position, 1);
+	fragmentNormal = mat3(model) * normal;
+	fragmentUV = uv;
+	fragmentColor = color;
 }
 ` + "\x00"
 
 var fragmentShader = `
 #version 330
 
+in vec3 fragmentNormal;
+in vec2 fragmentUV;
+in vec4 fragmentColor;
 out vec4 outputColor;
 void main() {
-	outputColor = vec4(0,0,0,0);
+	// Geometry without colours gets the default attribute value (0,0,0,1)
+	outputColor = fragmentColor;
 }
 ` + "\x00"
@@ -0,0 +1,100 @@
+package go_world
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"math"
+)
+
+type gridCell [3]int32
+
+// Uniform grid hashing particles by position for neighbour lookups.
+type spatialGrid struct {
+	cellSize  float32
+	cells     map[gridCell][]*Particle
+	count     int
+	min       mgl32.Vec3
+	max       mgl32.Vec3
+	maxRadius float32
+}
+
+func newSpatialGrid(cellSize float32) *spatialGrid {
+	grid := new(spatialGrid)
+	grid.cellSize = cellSize
+	grid.cells = make(map[gridCell][]*Particle)
+	return grid
+}
+
+func (g *spatialGrid) build(particles []*Particle) {
+	// Cells that stayed empty since the last build are dropped
+	for cell, items := range g.cells {
+		if len(items) == 0 {
+			delete(g.cells, cell)
+		} else {
+			g.cells[cell] = items[:0]
+		}
+	}
+	g.count = 0
+	g.maxRadius = 0
+	for _, p := range particles {
+		g.insert(p)
+	}
+}
+
+func (g *spatialGrid) insert(p *Particle) {
+	position := p.Position()
+	cell := g.cellOf(position)
+	g.cells[cell] = append(g.cells[cell], p)
+
+	if g.count == 0 {
+		g.min = position
+		g.max = position
+	}
+	for i := 0; i < 3; i++ {
+		g.min[i] = float32(math.Min(float64(g.min[i]), float64(position[i])))
+		g.max[i] = float32(math.Max(float64(g.max[i]), float64(position[i])))
+	}
+	if p.Radius() > g.maxRadius {
+		g.maxRadius = p.Radius()
+	}
+	g.count++
+}
+
+func (g *spatialGrid) cellOf(position mgl32.Vec3) gridCell {
+	return gridCell{
+		int32(math.Floor(float64(position[0] / g.cellSize))),
+		int32(math.Floor(float64(position[1] / g.cellSize))),
+		int32(math.Floor(float64(position[2] / g.cellSize))),
+	}
+}
+
+// Calls fn for every particle in the cells overlapping the sphere around
+// center. Candidates still have to be tested against the exact radius.
+func (g *spatialGrid) forEachNear(center mgl32.Vec3, radius float32, fn func(p *Particle)) {
+	offset := mgl32.Vec3{radius, radius, radius}
+	g.forEachInBox(center.Sub(offset), center.Add(offset), fn)
+}
+
+func (g *spatialGrid) forEachInBox(min, max mgl32.Vec3, fn func(p *Particle)) {
+	if g.count == 0 {
+		return
+	}
+	// Only the occupied part of the grid is visited
+	for i := 0; i < 3; i++ {
+		if min[i] > g.max[i] || max[i] < g.min[i] {
+			return
+		}
+		min[i] = float32(math.Max(float64(min[i]), float64(g.min[i])))
+		max[i] = float32(math.Min(float64(max[i]), float64(g.max[i])))
+	}
+	low := g.cellOf(min)
+	high := g.cellOf(max)
+	for x := low[0]; x <= high[0]; x++ {
+		for y := low[1]; y <= high[1]; y++ {
+			for z := low[2]; z <= high[2]; z++ {
+				for _, p := range g.cells[gridCell{x, y, z}] {
+					fn(p)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package go_world
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"math"
+	"sort"
+)
+
+// Spatial queries over the particles of a ParticleSystem. They share a grid
+// index that is rebuilt lazily after every Update or change of the particle
+// set. Positions changed from outside of Update need a call to
+// InvalidateSpatialIndex.
+
+// Cell size of the spatial index, 0 picks twice the largest particle radius.
+func (ps *ParticleSystem) SetSpatialIndexCellSize(size float32) {
+	ps.indexCellSize = size
+	ps.InvalidateSpatialIndex()
+}
+
+func (ps *ParticleSystem) InvalidateSpatialIndex() {
+	ps.indexValid = false
+}
+
+func (ps *ParticleSystem) spatialIndex() *spatialGrid {
+	if ps.index != nil && ps.indexValid {
+		return ps.index
+	}
+
+	size := ps.indexCellSize
+	if size <= 0 {
+		for _, p := range ps.particles {
+			if 2*p.Radius() > size {
+				size = 2 * p.Radius()
+			}
+		}
+		if size <= 0 {
+			size = 1
+		}
+	}
+	if ps.index == nil || ps.index.cellSize != size {
+		ps.index = newSpatialGrid(size)
+	}
+	ps.index.build(ps.particles)
+	ps.indexValid = true
+	return ps.index
+}
+
+// Particles whose center lies within radius of center
+func (ps *ParticleSystem) ParticlesWithinRadius(center mgl32.Vec3, radius float32) []*Particle {
+	var result []*Particle
+	ps.spatialIndex().forEachNear(center, radius, func(p *Particle) {
+		if p.Position().Sub(center).Len() <= radius {
+			result = append(result, p)
+		}
+	})
+	return result
+}
+
+// Particles whose center lies inside the axis aligned box
+func (ps *ParticleSystem) ParticlesInBox(min, max mgl32.Vec3) []*Particle {
+	var result []*Particle
+	ps.spatialIndex().forEachInBox(min, max, func(p *Particle) {
+		position := p.Position()
+		for i := 0; i < 3; i++ {
+			if position[i] < min[i] || position[i] > max[i] {
+				return
+			}
+		}
+		result = append(result, p)
+	})
+	return result
+}
+
+// The k particles closest to point, nearest first
+func (ps *ParticleSystem) KNearest(point mgl32.Vec3, k int) []*Particle {
+	index := ps.spatialIndex()
+	if k <= 0 || index.count == 0 {
+		return nil
+	}
+
+	// Grow the search sphere until it holds k particles or the whole grid
+	radius := index.cellSize
+	for {
+		candidates := ps.ParticlesWithinRadius(point, radius)
+		if len(candidates) >= k || sphereContainsBox(point, radius, index.min, index.max) {
+			sort.Slice(candidates, func(i, j int) bool {
+				return candidates[i].Position().Sub(point).Len() < candidates[j].Position().Sub(point).Len()
+			})
+			if len(candidates) > k {
+				candidates = candidates[:k]
+			}
+			return candidates
+		}
+		radius *= 2
+	}
+}
+
+// First particle hit by the ray from origin along direction, treating
+// particles as spheres. A maxDistance of 0 does not limit the ray. Returns
+// nil if nothing is hit, otherwise the particle and the distance to it.
+func (ps *ParticleSystem) Raycast(origin, direction mgl32.Vec3, maxDistance float32) (*Particle, float32) {
+	index := ps.spatialIndex()
+	if index.count == 0 || direction.Len() == 0 {
+		return nil, 0
+	}
+	direction = direction.Normalize()
+
+	margin := mgl32.Vec3{index.maxRadius, index.maxRadius, index.maxRadius}
+	tEnter, tExit, ok := rayBoxIntersection(origin, direction, index.min.Sub(margin), index.max.Add(margin))
+	if !ok {
+		return nil, 0
+	}
+	if maxDistance > 0 && maxDistance < tExit {
+		tExit = maxDistance
+	}
+	t := float32(math.Max(float64(tEnter), 0))
+	if t > tExit {
+		return nil, 0
+	}
+
+	// Voxel traversal, see Amanatides and Woo
+	cell := index.cellOf(origin.Add(direction.Mul(t)))
+	var step gridCell
+	var tMax, tDelta [3]float32
+	for i := 0; i < 3; i++ {
+		tMax[i] = float32(math.Inf(1))
+		tDelta[i] = float32(math.Inf(1))
+		if direction[i] == 0 {
+			continue
+		}
+		boundary := float32(cell[i]) * index.cellSize
+		step[i] = -1
+		if direction[i] > 0 {
+			step[i] = 1
+			boundary += index.cellSize
+		}
+		tMax[i] = (boundary - origin[i]) / direction[i]
+		tDelta[i] = index.cellSize / float32(math.Abs(float64(direction[i])))
+	}
+
+	// Particles stored in neighbouring cells can reach into the current one
+	reach := int32(math.Ceil(float64(index.maxRadius / index.cellSize)))
+	tested := make(map[*Particle]bool)
+	var hit *Particle
+	best := tExit
+
+	for t <= tExit && t <= best {
+		for x := cell[0] - reach; x <= cell[0]+reach; x++ {
+			for y := cell[1] - reach; y <= cell[1]+reach; y++ {
+				for z := cell[2] - reach; z <= cell[2]+reach; z++ {
+					for _, p := range index.cells[gridCell{x, y, z}] {
+						if tested[p] {
+							continue
+						}
+						tested[p] = true
+						if d, ok := raySphereIntersection(origin, direction, p.Position(), p.Radius()); ok && d <= best {
+							hit = p
+							best = d
+						}
+					}
+				}
+			}
+		}
+
+		axis := 0
+		if tMax[1] < tMax[axis] {
+			axis = 1
+		}
+		if tMax[2] < tMax[axis] {
+			axis = 2
+		}
+		t = tMax[axis]
+		cell[axis] += step[axis]
+		tMax[axis] += tDelta[axis]
+	}
+
+	if hit == nil {
+		return nil, 0
+	}
+	return hit, best
+}
+
+// Distance along the normalized direction to the sphere, 0 if origin is
+// inside of it.
+func raySphereIntersection(origin, direction, center mgl32.Vec3, radius float32) (float32, bool) {
+	oc := origin.Sub(center)
+	b := oc.Dot(direction)
+	c := oc.Dot(oc) - radius*radius
+	discriminant := b*b - c
+	if discriminant < 0 {
+		return 0, false
+	}
+	root := float32(math.Sqrt(float64(discriminant)))
+	if -b+root < 0 {
+		return 0, false
+	}
+	return float32(math.Max(float64(-b-root), 0)), true
+}
+
+func rayBoxIntersection(origin, direction, min, max mgl32.Vec3) (float32, float32, bool) {
+	tEnter := float32(math.Inf(-1))
+	tExit := float32(math.Inf(1))
+	for i := 0; i < 3; i++ {
+		if direction[i] == 0 {
+			if origin[i] < min[i] || origin[i] > max[i] {
+				return 0, 0, false
+			}
+			continue
+		}
+		t1 := (min[i] - origin[i]) / direction[i]
+		t2 := (max[i] - origin[i]) / direction[i]
+		if t1 > t2 {
+			t1, t2 = t2, t1
+		}
+		tEnter = float32(math.Max(float64(tEnter), float64(t1)))
+		tExit = float32(math.Min(float64(tExit), float64(t2)))
+	}
+	return tEnter, tExit, tEnter <= tExit && tExit >= 0
+}
+
+func sphereContainsBox(center mgl32.Vec3, radius float32, min, max mgl32.Vec3) bool {
+	var farthest mgl32.Vec3
+	for i := 0; i < 3; i++ {
+		farthest[i] = float32(math.Max(math.Abs(float64(min[i]-center[i])), math.Abs(float64(max[i]-center[i]))))
+	}
+	return farthest.Len() <= radius
+}
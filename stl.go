@@ -0,0 +1,228 @@
+package go_world
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"strings"
+)
+
+// Facet record of binary STL files
+type stlTriangle struct {
+	Normal    [3]float32
+	Vertices  [3][3]float32
+	Attribute uint16
+}
+
+func LoadSTLFile(path string) (*Geometry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	geometry, err := LoadSTL(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return geometry, nil
+}
+
+// Reads ASCII or binary STL into a triangle list with flat normals. Binary
+// files are recognized by their size, as some exporters start the binary
+// header with "solid" too.
+func LoadSTL(r io.Reader) (*Geometry, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) >= 84 {
+		count := binary.LittleEndian.Uint32(data[80:84])
+		if uint64(len(data)) == 84+50*uint64(count) {
+			return loadBinarySTL(data[84:], int(count))
+		}
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("solid")) {
+		return loadASCIISTL(data)
+	}
+	return nil, errors.New("neither ASCII nor binary STL")
+}
+
+func loadBinarySTL(data []byte, count int) (*Geometry, error) {
+	triangles := make([]stlTriangle, count)
+	if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, triangles); err != nil {
+		return nil, fmt.Errorf("reading %d triangles: %v", count, err)
+	}
+	var corners []mgl32.Vec3
+	var normals []mgl32.Vec3
+	for _, t := range triangles {
+		for _, v := range t.Vertices {
+			corners = append(corners, v)
+		}
+		normal := stlNormal(t.Normal, corners[len(corners)-3:])
+		normals = append(normals, normal, normal, normal)
+	}
+	return stlGeometry(corners, normals), nil
+}
+
+func loadASCIISTL(data []byte) (*Geometry, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	var corners []mgl32.Vec3
+	var normals []mgl32.Vec3
+	var normal mgl32.Vec3
+	inFacet := false
+	facetCorners := 0
+	line := 0
+
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "solid", "endsolid", "outer", "endloop":
+		case "facet":
+			if inFacet {
+				return nil, fmt.Errorf("line %d: facet inside facet", line)
+			}
+			if len(fields) != 5 || fields[1] != "normal" {
+				return nil, fmt.Errorf("line %d: expected facet normal x y z", line)
+			}
+			var err error
+			if normal, err = parseVec3(fields[2:]); err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			inFacet = true
+			facetCorners = 0
+		case "vertex":
+			if !inFacet {
+				return nil, fmt.Errorf("line %d: vertex outside of facet", line)
+			}
+			if len(fields) != 4 {
+				return nil, fmt.Errorf("line %d: expected vertex x y z", line)
+			}
+			if facetCorners == 3 {
+				return nil, fmt.Errorf("line %d: facet with more than 3 vertices", line)
+			}
+			v, err := parseVec3(fields[1:])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			corners = append(corners, v)
+			facetCorners++
+		case "endfacet":
+			if !inFacet || facetCorners != 3 {
+				return nil, fmt.Errorf("line %d: facet needs 3 vertices", line)
+			}
+			n := stlNormal(normal, corners[len(corners)-3:])
+			normals = append(normals, n, n, n)
+			inFacet = false
+		default:
+			return nil, fmt.Errorf("line %d: unexpected %q", line, fields[0])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if inFacet {
+		return nil, errors.New("unterminated facet")
+	}
+	return stlGeometry(corners, normals), nil
+}
+
+// The stored normal unless it is missing, which many exporters write as zero
+func stlNormal(stored mgl32.Vec3, corners []mgl32.Vec3) mgl32.Vec3 {
+	if stored.Len() > 0 {
+		return stored.Normalize()
+	}
+	n := corners[1].Sub(corners[0]).Cross(corners[2].Sub(corners[0]))
+	if n.Len() > 0 {
+		return n.Normalize()
+	}
+	return n
+}
+
+func stlGeometry(corners, normals []mgl32.Vec3) *Geometry {
+	geometry := new(Geometry)
+	geometry.vertices = to_array(corners...)
+	geometry.SetNormals(normals...)
+	geometry.draw_method = gl.TRIANGLES
+	return geometry
+}
+
+func (g *Geometry) stlTriangles() ([]Triangle, error) {
+	if g.draw_method != gl.TRIANGLES {
+		return nil, errors.New("STL needs a triangle list")
+	}
+	return g.Triangles(), nil
+}
+
+// Writes the triangles of the geometry as binary STL, name goes into the
+// 80 byte header
+func (g *Geometry) WriteSTL(w io.Writer, name string) error {
+	triangles, err := g.stlTriangles()
+	if err != nil {
+		return err
+	}
+	var header [80]byte
+	copy(header[:], name)
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(triangles))); err != nil {
+		return err
+	}
+
+	buffered := bufio.NewWriter(w)
+	for _, t := range triangles {
+		record := stlTriangle{Normal: t.Normal()}
+		for i, v := range []mgl32.Vec3{t.V1, t.V2, t.V3} {
+			record.Vertices[i] = v
+		}
+		if err := binary.Write(buffered, binary.LittleEndian, record); err != nil {
+			return err
+		}
+	}
+	return buffered.Flush()
+}
+
+func (g *Geometry) WriteSTLASCII(w io.Writer, name string) error {
+	triangles, err := g.stlTriangles()
+	if err != nil {
+		return err
+	}
+	// Names can not span lines
+	name = strings.Join(strings.Fields(name), "_")
+
+	buffered := bufio.NewWriter(w)
+	fmt.Fprintf(buffered, "solid %s\n", name)
+	for _, t := range triangles {
+		n := t.Normal()
+		fmt.Fprintf(buffered, "  facet normal %s %s %s\n", stlFloat(n[0]), stlFloat(n[1]), stlFloat(n[2]))
+		fmt.Fprintf(buffered, "    outer loop\n")
+		for _, v := range []mgl32.Vec3{t.V1, t.V2, t.V3} {
+			fmt.Fprintf(buffered, "      vertex %s %s %s\n", stlFloat(v[0]), stlFloat(v[1]), stlFloat(v[2]))
+		}
+		fmt.Fprintf(buffered, "    endloop\n")
+		fmt.Fprintf(buffered, "  endfacet\n")
+	}
+	fmt.Fprintf(buffered, "endsolid %s\n", name)
+	return buffered.Flush()
+}
+
+func stlFloat(x float32) string {
+	if x == 0 || math.IsNaN(float64(x)) {
+		// Avoids -0 and NaN, which CAD tools reject
+		return "0"
+	}
+	return fmt.Sprintf("%e", x)
+}
@@ -0,0 +1,65 @@
+package go_world
+
+import (
+	"math"
+)
+
+// Kinetic temperature of the particles in units where the Boltzmann constant
+// is 1, i.e. the mean of m*v² per degree of freedom. Dimensions is the
+// number of spatial dimensions particles move in.
+func KineticTemperature(particles []*Particle, dimensions int) float32 {
+	if len(particles) == 0 || dimensions <= 0 {
+		return 0
+	}
+	var sum float64
+	for _, p := range particles {
+		v := p.Velocity()
+		sum += float64(p.Mass() * v.Dot(v))
+	}
+	return float32(sum / float64(dimensions*len(particles)))
+}
+
+// Weakly couples the system to a heat bath, the temperature relaxes to
+// Target with the time constant Tau.
+type BerendsenThermostat struct {
+	Target     float32
+	Tau        float32
+	Dimensions int
+}
+
+func NewBerendsenThermostat(target, tau float32) *BerendsenThermostat {
+	return &BerendsenThermostat{Target: target, Tau: tau, Dimensions: 3}
+}
+
+func (t *BerendsenThermostat) Apply(particles []*Particle, time_delta float32) {
+	temperature := KineticTemperature(particles, t.Dimensions)
+	if temperature == 0 || t.Tau <= 0 {
+		return
+	}
+	factor := 1 + time_delta/t.Tau*(t.Target/temperature-1)
+	scaleVelocities(particles, float32(math.Sqrt(math.Max(float64(factor), 0))))
+}
+
+// Rescales all velocities to Target every step
+type VelocityRescalingThermostat struct {
+	Target     float32
+	Dimensions int
+}
+
+func NewVelocityRescalingThermostat(target float32) *VelocityRescalingThermostat {
+	return &VelocityRescalingThermostat{Target: target, Dimensions: 3}
+}
+
+func (t *VelocityRescalingThermostat) Apply(particles []*Particle, time_delta float32) {
+	temperature := KineticTemperature(particles, t.Dimensions)
+	if temperature == 0 {
+		return
+	}
+	scaleVelocities(particles, float32(math.Sqrt(float64(t.Target/temperature))))
+}
+
+func scaleVelocities(particles []*Particle, factor float32) {
+	for _, p := range particles {
+		p.velocity = p.velocity.Mul(factor)
+	}
+}
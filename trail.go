@@ -0,0 +1,125 @@
+package go_world
+
+import (
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Fading polyline through the recent positions of a particle. Positions are
+// kept in a ring buffer and sampled every interval seconds of simulation
+// time.
+type Trail struct {
+	positions []mgl32.Vec3
+	first     int
+	count     int
+	interval  float32
+	elapsed   float32
+	head      mgl32.Vec4
+	tail      mgl32.Vec4
+	object    *Object
+	scene     *Scene
+}
+
+func NewTrail(scene *Scene, length int, interval float32) *Trail {
+	trail := new(Trail)
+	trail.positions = make([]mgl32.Vec3, length)
+	trail.interval = interval
+	trail.head = mgl32.Vec4{0, 0, 0, 1}
+	trail.tail = mgl32.Vec4{0, 0, 0, 0}
+	trail.scene = scene
+	return trail
+}
+
+// Colour at the newest and the oldest end of the trail, interpolated in
+// between.
+func (t *Trail) SetColors(head, tail mgl32.Vec4) *Trail {
+	t.head = head
+	t.tail = tail
+	return t
+}
+
+func (t *Trail) SetInterval(interval float32) *Trail {
+	t.interval = interval
+	return t
+}
+
+func (t *Trail) Length() int {
+	return len(t.positions)
+}
+
+// Positions from the oldest to the newest
+func (t *Trail) Points() []mgl32.Vec3 {
+	points := make([]mgl32.Vec3, 0, t.count)
+	for i := 0; i < t.count; i++ {
+		points = append(points, t.positions[(t.first+i)%len(t.positions)])
+	}
+	return points
+}
+
+func (t *Trail) Clear() {
+	t.first = 0
+	t.count = 0
+	t.elapsed = 0
+	t.rebuild(nil)
+}
+
+func (t *Trail) Object() *Object {
+	return t.object
+}
+
+func (t *Trail) push(position mgl32.Vec3) {
+	if len(t.positions) == 0 {
+		return
+	}
+	if t.count < len(t.positions) {
+		t.positions[(t.first+t.count)%len(t.positions)] = position
+		t.count++
+	} else {
+		t.positions[t.first] = position
+		t.first = (t.first + 1) % len(t.positions)
+	}
+}
+
+func (t *Trail) update(position mgl32.Vec3, time_delta float32) {
+	t.elapsed += time_delta
+	if t.count == 0 || t.elapsed >= t.interval {
+		t.push(position)
+		t.elapsed = 0
+	}
+
+	// The newest segment follows the particle between samples
+	points := append(t.Points(), position)
+	t.rebuild(points)
+}
+
+func (t *Trail) rebuild(points []mgl32.Vec3) {
+	if len(points) < 2 {
+		if t.object != nil {
+			t.remove()
+		}
+		return
+	}
+
+	colors := make([]mgl32.Vec4, len(points))
+	for i := range points {
+		f := float32(i) / float32(len(points)-1)
+		colors[i] = t.tail.Add(t.head.Sub(t.tail).Mul(f))
+	}
+	geometry := CreateLineStripGeometry(points...).SetColors(colors...)
+
+	if t.object == nil {
+		t.object = NewObject(geometry)
+		t.object.configure(t.scene.program)
+		t.scene.addObject(t.object)
+		return
+	}
+	t.object.geometry = geometry
+	t.object.updateBuffers(gl.DYNAMIC_DRAW)
+}
+
+func (t *Trail) remove() {
+	if t.object != nil {
+		t.scene.RemoveObject(t.object)
+		t.object = nil
+	}
+}
@@ -0,0 +1,310 @@
+package go_world
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-gl/mathgl/mgl32"
+	"math"
+	"sort"
+)
+
+type point64 struct {
+	x, y float64
+}
+
+func (a point64) sub(b point64) point64 {
+	return point64{a.x - b.x, a.y - b.y}
+}
+
+func cross2(a, b point64) float64 {
+	return a.x*b.y - a.y*b.x
+}
+
+// Twice the signed area, positive for counter clockwise loops
+func signedArea(points []point64, loop []int) float64 {
+	area := 0.0
+	for i, index := range loop {
+		next := points[loop[(i+1)%len(loop)]]
+		area += cross2(points[index], next)
+	}
+	return area
+}
+
+// Triangulates a simple polygon, convex or not, by ear clipping. Holes are
+// simple loops inside the outline that do not touch each other. Points are
+// numbered outline first, then the holes in order, the returned triangles
+// index into them and are counter clockwise. Loops may have either
+// orientation.
+func TriangulatePolygon(outline []mgl32.Vec2, holes ...[]mgl32.Vec2) ([]uint32, error) {
+	if len(outline) < 3 {
+		return nil, fmt.Errorf("outline needs at least 3 points, got %d", len(outline))
+	}
+	var points []point64
+	addLoop := func(loop []mgl32.Vec2) []int {
+		indices := make([]int, len(loop))
+		for i, p := range loop {
+			indices[i] = len(points)
+			points = append(points, point64{float64(p[0]), float64(p[1])})
+		}
+		return indices
+	}
+
+	outer := addLoop(outline)
+	if signedArea(points, outer) < 0 {
+		reverseInts(outer)
+	}
+	var holeLoops []holeLoop
+	for i, hole := range holes {
+		if len(hole) < 3 {
+			return nil, fmt.Errorf("hole %d needs at least 3 points, got %d", i, len(hole))
+		}
+		loop := addLoop(hole)
+		// Holes run the other way round than the outline
+		if signedArea(points, loop) > 0 {
+			reverseInts(loop)
+		}
+		holeLoops = append(holeLoops, holeLoop{loop, i})
+	}
+
+	polygon, err := bridgeHoles(points, outer, holeLoops)
+	if err != nil {
+		return nil, err
+	}
+	return clipEars(points, polygon)
+}
+
+func reverseInts(values []int) {
+	for i, j := 0, len(values)-1; i < j; i, j = i+1, j-1 {
+		values[i], values[j] = values[j], values[i]
+	}
+}
+
+// Hole with its position in the arguments, for errors
+type holeLoop struct {
+	points   []int
+	argument int
+}
+
+// Joins the holes to the outline by pairs of coincident edges, giving a
+// single loop that visits every point. Holes are joined from the one
+// reaching furthest along +x, each connecting its rightmost point to a
+// visible point of the loop so far.
+func bridgeHoles(points []point64, outer []int, holes []holeLoop) ([]int, error) {
+	rightmost := func(loop []int) int {
+		best := 0
+		for i, index := range loop {
+			p, b := points[index], points[loop[best]]
+			if p.x > b.x || (p.x == b.x && p.y < b.y) {
+				best = i
+			}
+		}
+		return best
+	}
+	sort.Slice(holes, func(i, j int) bool {
+		a, b := holes[i].points, holes[j].points
+		return points[a[rightmost(a)]].x > points[b[rightmost(b)]].x
+	})
+
+	polygon := append([]int(nil), outer...)
+	for _, h := range holes {
+		hole := h.points
+		start := rightmost(hole)
+		m := points[hole[start]]
+		bridge, ok := visiblePoint(points, polygon, m)
+		if !ok {
+			return nil, fmt.Errorf("hole %d is not inside the outline", h.argument)
+		}
+
+		joined := make([]int, 0, len(polygon)+len(hole)+2)
+		joined = append(joined, polygon[:bridge+1]...)
+		for i := 0; i <= len(hole); i++ {
+			joined = append(joined, hole[(start+i)%len(hole)])
+		}
+		joined = append(joined, polygon[bridge:]...)
+		polygon = joined
+	}
+	return polygon, nil
+}
+
+// Position in polygon of a point that can be connected to m without
+// crossing an edge, after Eberly's "Triangulation by Ear Clipping"
+func visiblePoint(points []point64, polygon []int, m point64) (int, bool) {
+	// Closest edge hit by the ray from m along +x
+	best := -1
+	hitX := math.Inf(1)
+	for i := range polygon {
+		a, b := points[polygon[i]], points[polygon[(i+1)%len(polygon)]]
+		// Only edges running upwards face m with the inside of the polygon
+		if a.y > m.y || b.y < m.y || a.y == b.y {
+			continue
+		}
+		x := a.x + (m.y-a.y)*(b.x-a.x)/(b.y-a.y)
+		if x >= m.x && x < hitX {
+			hitX = x
+			best = i
+		}
+	}
+	if best < 0 {
+		return 0, false
+	}
+	a, b := polygon[best], polygon[(best+1)%len(polygon)]
+	hit := point64{hitX, m.y}
+	if points[a] == hit {
+		return best, true
+	}
+	if points[b] == hit {
+		return (best + 1) % len(polygon), true
+	}
+
+	// The endpoint with larger x is visible unless reflex points lie in the
+	// triangle of m, the hit and that endpoint. Then the one with the
+	// smallest angle to the ray is.
+	candidate := best
+	if points[b].x > points[a].x {
+		candidate = (best + 1) % len(polygon)
+	}
+	p := points[polygon[candidate]]
+	bestAngle := math.Inf(1)
+	bestDistance := math.Inf(1)
+	for i, index := range polygon {
+		q := points[index]
+		if q == p || !isReflex(points, polygon, i) {
+			continue
+		}
+		if !pointInTriangle(q, m, hit, p) && !pointInTriangle(q, m, p, hit) {
+			continue
+		}
+		d := q.sub(m)
+		angle := math.Abs(math.Atan2(d.y, d.x))
+		distance := math.Hypot(d.x, d.y)
+		if angle < bestAngle || (angle == bestAngle && distance < bestDistance) {
+			bestAngle = angle
+			bestDistance = distance
+			candidate = i
+		}
+	}
+	return candidate, true
+}
+
+func isReflex(points []point64, polygon []int, i int) bool {
+	n := len(polygon)
+	a := points[polygon[(i+n-1)%n]]
+	b := points[polygon[i]]
+	c := points[polygon[(i+1)%n]]
+	return cross2(b.sub(a), c.sub(b)) < 0
+}
+
+// Inclusive of the edges, for a counter clockwise triangle
+func pointInTriangle(p, a, b, c point64) bool {
+	return cross2(b.sub(a), p.sub(a)) >= 0 &&
+		cross2(c.sub(b), p.sub(b)) >= 0 &&
+		cross2(a.sub(c), p.sub(c)) >= 0
+}
+
+// Repeatedly cuts off a convex corner that contains no other point
+func clipEars(points []point64, polygon []int) ([]uint32, error) {
+	n := len(polygon)
+	prev := make([]int, n)
+	next := make([]int, n)
+	for i := range polygon {
+		prev[i] = (i + n - 1) % n
+		next[i] = (i + 1) % n
+	}
+
+	isEar := func(i int) bool {
+		a, b, c := points[polygon[prev[i]]], points[polygon[i]], points[polygon[next[i]]]
+		if cross2(b.sub(a), c.sub(b)) <= 0 {
+			return false
+		}
+		for j := next[next[i]]; j != prev[i]; j = next[j] {
+			p := points[polygon[j]]
+			// Bridge points appear twice
+			if p == a || p == b || p == c {
+				continue
+			}
+			if pointInTriangle(p, a, b, c) {
+				return false
+			}
+		}
+		return true
+	}
+
+	cut := func(i int) {
+		next[prev[i]] = next[i]
+		prev[next[i]] = prev[i]
+	}
+
+	indices := make([]uint32, 0, (n-2)*3)
+	remaining := n
+	i := 0
+	stalled := 0
+	for remaining > 3 {
+		if isEar(i) {
+			indices = append(indices, uint32(polygon[prev[i]]), uint32(polygon[i]), uint32(polygon[next[i]]))
+			cut(i)
+			remaining--
+			i = next[i]
+			stalled = 0
+			continue
+		}
+		i = next[i]
+		stalled++
+		if stalled <= remaining {
+			continue
+		}
+		// No ear left, only collinear points and spikes covering no area
+		// may still be dropped
+		found := false
+		for j, k := i, 0; k < remaining; j, k = next[j], k+1 {
+			a, b, c := points[polygon[prev[j]]], points[polygon[j]], points[polygon[next[j]]]
+			if cross2(b.sub(a), c.sub(b)) == 0 {
+				cut(j)
+				remaining--
+				i = next[j]
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.New("polygon is not simple")
+		}
+		stalled = 0
+	}
+	a, b, c := points[polygon[prev[i]]], points[polygon[i]], points[polygon[next[i]]]
+	if cross2(b.sub(a), c.sub(b)) > 0 {
+		indices = append(indices, uint32(polygon[prev[i]]), uint32(polygon[i]), uint32(polygon[next[i]]))
+	}
+	return indices, nil
+}
+
+// Filled polygon in the plane of the points' x and y, facing +z. The
+// outline may be concave and the holes are cut out of it. Texture
+// coordinates map the bounding rectangle to [0, 1]².
+func CreatePolygonGeometry(outline []mgl32.Vec3, holes ...[]mgl32.Vec3) (*Geometry, error) {
+	flat := func(loop []mgl32.Vec3) []mgl32.Vec2 {
+		points := make([]mgl32.Vec2, len(loop))
+		for i, p := range loop {
+			points[i] = p.Vec2()
+		}
+		return points
+	}
+	var flatHoles [][]mgl32.Vec2
+	all := append([]mgl32.Vec3(nil), outline...)
+	for _, hole := range holes {
+		flatHoles = append(flatHoles, flat(hole))
+		all = append(all, hole...)
+	}
+	indices, err := TriangulatePolygon(flat(outline), flatHoles...)
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := NewAABB(all...)
+	b := new(meshBuilder)
+	for _, p := range all {
+		b.vertex(p, mgl32.Vec3{0, 0, 1}, rectangleUV(p, bounds.Min, bounds.Max))
+	}
+	b.indices = indices
+
+	return b.geometry(), nil
+}
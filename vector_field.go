@@ -0,0 +1,346 @@
+package go_world
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/go-gl/mathgl/mgl32"
+	"image"
+	"io"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Regular grid of vectors. Node (i, j, k) lies at Origin + (i, j, k) *
+// Spacing, a 2D field has a single node along z.
+type VectorField struct {
+	Dims    [3]int
+	Origin  mgl32.Vec3
+	Spacing mgl32.Vec3
+	Vectors []mgl32.Vec3
+}
+
+func NewVectorField(nx, ny, nz int, origin, spacing mgl32.Vec3) *VectorField {
+	field := new(VectorField)
+	field.Dims = [3]int{nx, ny, nz}
+	field.Origin = origin
+	field.Spacing = spacing
+	field.Vectors = make([]mgl32.Vec3, nx*ny*nz)
+	return field
+}
+
+func (f *VectorField) index(i, j, k int) int {
+	return (k*f.Dims[1]+j)*f.Dims[0] + i
+}
+
+func (f *VectorField) At(i, j, k int) mgl32.Vec3 {
+	return f.Vectors[f.index(i, j, k)]
+}
+
+func (f *VectorField) Set(i, j, k int, v mgl32.Vec3) {
+	f.Vectors[f.index(i, j, k)] = v
+}
+
+// Trilinear interpolation, positions outside of the grid get the value at
+// the nearest edge.
+func (f *VectorField) Sample(position mgl32.Vec3) mgl32.Vec3 {
+	var low, high [3]int
+	var fraction [3]float32
+	for axis := 0; axis < 3; axis++ {
+		n := f.Dims[axis]
+		if n < 2 || f.Spacing[axis] == 0 {
+			continue
+		}
+		x := (position[axis] - f.Origin[axis]) / f.Spacing[axis]
+		x = mgl32.Clamp(x, 0, float32(n-1))
+		i := int(math.Floor(float64(x)))
+		if i > n-2 {
+			i = n - 2
+		}
+		low[axis] = i
+		high[axis] = i + 1
+		fraction[axis] = x - float32(i)
+	}
+
+	var result mgl32.Vec3
+	for corner := 0; corner < 8; corner++ {
+		weight := float32(1)
+		var cell [3]int
+		for axis := 0; axis < 3; axis++ {
+			if corner&(1<<uint(axis)) != 0 {
+				cell[axis] = high[axis]
+				weight *= fraction[axis]
+			} else {
+				cell[axis] = low[axis]
+				weight *= 1 - fraction[axis]
+			}
+		}
+		if weight != 0 {
+			result = result.Add(f.At(cell[0], cell[1], cell[2]).Mul(weight))
+		}
+	}
+	return result
+}
+
+type FlowMode int
+
+const (
+	// The field is a force acting on the particles
+	FlowForce FlowMode = iota
+	// Particles are pulled towards the velocity of the field, Strength is
+	// the rate per second
+	FlowVelocity
+)
+
+// Force field following an authored or generated VectorField
+type FlowForceField struct {
+	Field    *VectorField
+	Strength float32
+	Mode     FlowMode
+}
+
+func NewFlowForceField(field *VectorField, strength float32, mode FlowMode) *FlowForceField {
+	return &FlowForceField{Field: field, Strength: strength, Mode: mode}
+}
+
+func (ff *FlowForceField) Apply(p *Particle, time_delta float32) {
+	flow := ff.Field.Sample(p.Position())
+	var dv mgl32.Vec3
+	switch ff.Mode {
+	case FlowVelocity:
+		rate := mgl32.Clamp(ff.Strength*time_delta, 0, 1)
+		dv = flow.Sub(p.Velocity()).Mul(rate)
+	default:
+		dv = flow.Mul(ff.Strength * time_delta / p.Mass())
+	}
+	p.ApplyForce(dv[0], dv[1], dv[2])
+}
+
+// Reads a field from CSV. The first record holds
+// nx,ny,nz,origin_x,origin_y,origin_z,spacing_x,spacing_y,spacing_z and is
+// followed by one vx,vy,vz record per node with x varying fastest, then y,
+// then z. Empty lines and lines starting with # are skipped.
+func LoadVectorFieldCSV(r io.Reader) (*VectorField, error) {
+	scanner := bufio.NewScanner(r)
+	var field *VectorField
+	node := 0
+	line := 0
+
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		values, err := parseFloats(strings.Split(text, ","))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+
+		if field == nil {
+			if len(values) != 9 {
+				return nil, fmt.Errorf("line %d: header needs 9 values, got %d", line, len(values))
+			}
+			if err := checkVectorFieldDims(float64(values[0]), float64(values[1]), float64(values[2])); err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			field = NewVectorField(int(values[0]), int(values[1]), int(values[2]),
+				mgl32.Vec3{values[3], values[4], values[5]},
+				mgl32.Vec3{values[6], values[7], values[8]})
+			continue
+		}
+
+		if len(values) != 3 {
+			return nil, fmt.Errorf("line %d: vector needs 3 values, got %d", line, len(values))
+		}
+		if node >= len(field.Vectors) {
+			return nil, fmt.Errorf("line %d: more vectors than grid nodes (%d)", line, len(field.Vectors))
+		}
+		field.Vectors[node] = mgl32.Vec3{values[0], values[1], values[2]}
+		node++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if field == nil {
+		return nil, errors.New("missing header")
+	}
+	if node != len(field.Vectors) {
+		return nil, fmt.Errorf("expected %d vectors, got %d", len(field.Vectors), node)
+	}
+	return field, nil
+}
+
+// Largest grid the loaders accept, 2^24 nodes take 192 MiB
+const maxVectorFieldNodes = 1 << 24
+
+// Dimensions are checked as floats so that huge values can not overflow
+func checkVectorFieldDims(nx, ny, nz float64) error {
+	for _, n := range []float64{nx, ny, nz} {
+		// Written so that NaN fails too
+		if !(n >= 1) {
+			return errors.New("grid dimensions must be positive")
+		}
+		if n != math.Trunc(n) {
+			return fmt.Errorf("grid dimension %g is not a whole number", n)
+		}
+	}
+	if nx*ny*nz > maxVectorFieldNodes {
+		return fmt.Errorf("grid of %gx%gx%g nodes exceeds %d nodes", nx, ny, nz, maxVectorFieldNodes)
+	}
+	return nil
+}
+
+func parseFloats(fields []string) ([]float32, error) {
+	values := make([]float32, 0, len(fields))
+	for _, field := range fields {
+		value, err := strconv.ParseFloat(strings.TrimSpace(field), 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", strings.TrimSpace(field))
+		}
+		values = append(values, float32(value))
+	}
+	return values, nil
+}
+
+var vectorFieldMagic = [4]byte{'V', 'F', 'L', 'D'}
+
+type vectorFieldHeader struct {
+	Magic   [4]byte
+	Dims    [3]uint32
+	Origin  [3]float32
+	Spacing [3]float32
+}
+
+// Reads the little endian binary format written by WriteBinary: the magic
+// "VFLD", three uint32 dimensions, origin and spacing as float32 and the
+// vectors as float32 triples in the same order as the CSV format.
+func LoadVectorFieldBinary(r io.Reader) (*VectorField, error) {
+	var header vectorFieldHeader
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return nil, fmt.Errorf("reading header: %v", err)
+	}
+	if header.Magic != vectorFieldMagic {
+		return nil, errors.New("not a vector field file")
+	}
+	dims := header.Dims
+	if err := checkVectorFieldDims(float64(dims[0]), float64(dims[1]), float64(dims[2])); err != nil {
+		return nil, err
+	}
+
+	// Reading before allocating the field keeps a truncated file with a
+	// large header from reserving the whole grid
+	count := int(dims[0]) * int(dims[1]) * int(dims[2])
+	data, err := ioutil.ReadAll(io.LimitReader(r, int64(count)*12))
+	if err != nil {
+		return nil, fmt.Errorf("reading %d vectors: %v", count, err)
+	}
+	if len(data) != count*12 {
+		return nil, fmt.Errorf("expected %d vectors, got %d bytes", count, len(data))
+	}
+	field := NewVectorField(int(dims[0]), int(dims[1]), int(dims[2]), header.Origin, header.Spacing)
+	if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, field.Vectors); err != nil {
+		return nil, fmt.Errorf("reading %d vectors: %v", count, err)
+	}
+	return field, nil
+}
+
+func (f *VectorField) WriteBinary(w io.Writer) error {
+	header := vectorFieldHeader{
+		Magic:   vectorFieldMagic,
+		Dims:    [3]uint32{uint32(f.Dims[0]), uint32(f.Dims[1]), uint32(f.Dims[2])},
+		Origin:  f.Origin,
+		Spacing: f.Spacing,
+	}
+	if err := binary.Write(w, binary.LittleEndian, header); err != nil {
+		return err
+	}
+	return binary.Write(w, binary.LittleEndian, f.Vectors)
+}
+
+// 2D field from the gradient of the image brightness, one node per pixel.
+// The image is placed in the xy plane with its top row at the largest y, so
+// vectors point from dark to bright regions.
+func VectorFieldFromImageGradient(img image.Image, origin mgl32.Vec3, spacing float32) *VectorField {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	field := NewVectorField(width, height, 1, origin, mgl32.Vec3{spacing, spacing, 0})
+
+	brightness := func(x, y int) float32 {
+		x = clampInt(x, 0, width-1)
+		y = clampInt(y, 0, height-1)
+		r, g, b, _ := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+		return (0.299*float32(r) + 0.587*float32(g) + 0.114*float32(b)) / 0xffff
+	}
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			// Sobel operator, image rows grow downwards
+			gx := brightness(x+1, y-1) + 2*brightness(x+1, y) + brightness(x+1, y+1) -
+				brightness(x-1, y-1) - 2*brightness(x-1, y) - brightness(x-1, y+1)
+			gy := brightness(x-1, y-1) + 2*brightness(x, y-1) + brightness(x+1, y-1) -
+				brightness(x-1, y+1) - 2*brightness(x, y+1) - brightness(x+1, y+1)
+			field.Set(x, height-1-y, 0, mgl32.Vec3{gx / (8 * spacing), gy / (8 * spacing), 0})
+		}
+	}
+	return field
+}
+
+// Divergence free field from the curl of Perlin noise, particles following
+// it swirl without clumping. Scale is the size of the noise features in
+// world units. A field with nz = 1 is 2D.
+func CurlNoiseVectorField(nx, ny, nz int, origin, spacing mgl32.Vec3, scale float32, seed int64) *VectorField {
+	field := NewVectorField(nx, ny, nz, origin, spacing)
+	potentials := [3]*gradientNoise{newGradientNoise(seed), newGradientNoise(seed + 1), newGradientNoise(seed + 2)}
+	h := 1e-3 * float64(scale)
+
+	potential := func(n int, p [3]float64) float64 {
+		// Offsets keep the integer lattice of the noise away from the nodes
+		return potentials[n].at(p[0]/float64(scale)+0.31, p[1]/float64(scale)+0.17, p[2]/float64(scale)+0.73)
+	}
+	derivative := func(n, axis int, p [3]float64) float64 {
+		a, b := p, p
+		a[axis] -= h
+		b[axis] += h
+		return (potential(n, b) - potential(n, a)) / (2 * h)
+	}
+
+	for k := 0; k < nz; k++ {
+		for j := 0; j < ny; j++ {
+			for i := 0; i < nx; i++ {
+				p := [3]float64{
+					float64(origin[0] + float32(i)*spacing[0]),
+					float64(origin[1] + float32(j)*spacing[1]),
+					float64(origin[2] + float32(k)*spacing[2]),
+				}
+				var v mgl32.Vec3
+				if nz == 1 {
+					// Curl of the scalar stream function ψ = (0, 0, ψ)
+					v = mgl32.Vec3{float32(derivative(2, 1, p)), float32(-derivative(2, 0, p)), 0}
+				} else {
+					v = mgl32.Vec3{
+						float32(derivative(2, 1, p) - derivative(1, 2, p)),
+						float32(derivative(0, 2, p) - derivative(2, 0, p)),
+						float32(derivative(1, 0, p) - derivative(0, 1, p)),
+					}
+				}
+				field.Set(i, j, k, v.Mul(scale))
+			}
+		}
+	}
+	return field
+}
+
+func clampInt(value, min, max int) int {
+	if value < min {
+		return min
+	}
+	if value > max {
+		return max
+	}
+	return value
+}
@@ -0,0 +1,305 @@
+package go_world
+
+import (
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Names of the built-in attributes as declared in the vertex shader
+const (
+	PositionAttribute = "position"
+	NormalAttribute   = "normal"
+	UVAttribute       = "uv"
+	ColorAttribute    = "color"
+)
+
+// One vertex attribute inside an interleaved vertex. Size and Offset count
+// float32 components.
+type VertexAttribute struct {
+	Name   string
+	Size   int
+	Offset int
+}
+
+// How the attributes of a vertex are interleaved in the vertex buffer.
+// Stride is the number of float32 components per vertex.
+type VertexLayout struct {
+	Attributes []VertexAttribute
+	Stride     int
+}
+
+func (l VertexLayout) Equal(other VertexLayout) bool {
+	if l.Stride != other.Stride || len(l.Attributes) != len(other.Attributes) {
+		return false
+	}
+	for i, a := range l.Attributes {
+		if a != other.Attributes[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (l *VertexLayout) add(name string, size int) {
+	l.Attributes = append(l.Attributes, VertexAttribute{Name: name, Size: size, Offset: l.Stride})
+	l.Stride += size
+}
+
+// Per vertex data for attributes the built-in shader does not know about
+type customAttribute struct {
+	name   string
+	size   int
+	values []float32
+}
+
+// One unit normal per vertex
+func (g *Geometry) SetNormals(normals ...mgl32.Vec3) *Geometry {
+	g.normals = to_array(normals...)
+	return g
+}
+
+// One texture coordinate per vertex
+func (g *Geometry) SetUVs(uvs ...mgl32.Vec2) *Geometry {
+	g.uvs = g.uvs[:0]
+	for _, uv := range uvs {
+		g.uvs = append(g.uvs, uv[0], uv[1])
+	}
+	return g
+}
+
+// Adds or replaces an attribute with size components per vertex, bound to
+// the shader input of the same name. nil values remove it.
+func (g *Geometry) SetAttribute(name string, size int, values []float32) *Geometry {
+	for i, a := range g.custom {
+		if a.name == name {
+			if values == nil {
+				g.custom = append(g.custom[:i], g.custom[i+1:]...)
+			} else {
+				g.custom[i] = customAttribute{name, size, values}
+			}
+			return g
+		}
+	}
+	if values != nil {
+		g.custom = append(g.custom, customAttribute{name, size, values})
+	}
+	return g
+}
+
+func (g *Geometry) Normals() []float32 {
+	return g.normals
+}
+
+func (g *Geometry) UVs() []float32 {
+	return g.uvs
+}
+
+func (g *Geometry) Colors() []float32 {
+	return g.colors
+}
+
+// Attributes present in the geometry. Positions always come first, an
+// attribute missing for some vertices is left out entirely.
+func (g *Geometry) Layout() VertexLayout {
+	count := int(g.vertexCount())
+	var layout VertexLayout
+	layout.add(PositionAttribute, 3)
+	if count > 0 && len(g.normals) == count*3 {
+		layout.add(NormalAttribute, 3)
+	}
+	if count > 0 && len(g.uvs) == count*2 {
+		layout.add(UVAttribute, 2)
+	}
+	if count > 0 && len(g.colors) == count*4 {
+		layout.add(ColorAttribute, 4)
+	}
+	for _, a := range g.custom {
+		if count > 0 && len(a.values) == count*a.size {
+			layout.add(a.name, a.size)
+		}
+	}
+	return layout
+}
+
+// Vertex data in the order described by layout
+func (g *Geometry) interleave(layout VertexLayout) []float32 {
+	count := int(g.vertexCount())
+	data := make([]float32, count*layout.Stride)
+	for _, a := range layout.Attributes {
+		values := g.attributeValues(a.Name)
+		for i := 0; i < count; i++ {
+			copy(data[i*layout.Stride+a.Offset:], values[i*a.Size:(i+1)*a.Size])
+		}
+	}
+	return data
+}
+
+func (g *Geometry) attributeValues(name string) []float32 {
+	switch name {
+	case PositionAttribute:
+		return g.vertices
+	case NormalAttribute:
+		return g.normals
+	case UVAttribute:
+		return g.uvs
+	case ColorAttribute:
+		return g.colors
+	}
+	for _, a := range g.custom {
+		if a.name == name {
+			return a.values
+		}
+	}
+	return nil
+}
+
+// Points the attributes of the layout at the bound vertex buffer. Attributes
+// the program does not use are skipped.
+func bindVertexLayout(program uint32, layout VertexLayout) {
+	for _, a := range layout.Attributes {
+		location := gl.GetAttribLocation(program, gl.Str(a.Name+"\x00"))
+		if location < 0 {
+			continue
+		}
+		gl.EnableVertexAttribArray(uint32(location))
+		gl.VertexAttribPointer(uint32(location), int32(a.Size), gl.FLOAT, false,
+			int32(layout.Stride*4), gl.PtrOffset(a.Offset*4))
+	}
+}
+
+func unbindVertexLayout(program uint32, layout VertexLayout) {
+	for _, a := range layout.Attributes {
+		location := gl.GetAttribLocation(program, gl.Str(a.Name+"\x00"))
+		if location >= 0 {
+			gl.DisableVertexAttribArray(uint32(location))
+		}
+	}
+}
+
+// Normals of the triangles a triangle list is made of, shared by their
+// three vertices
+func flatNormals(vertices []float32) []float32 {
+	normals := make([]float32, len(vertices))
+	for i := 0; i+9 <= len(vertices); i += 9 {
+		a := mgl32.Vec3{vertices[i], vertices[i+1], vertices[i+2]}
+		b := mgl32.Vec3{vertices[i+3], vertices[i+4], vertices[i+5]}
+		c := mgl32.Vec3{vertices[i+6], vertices[i+7], vertices[i+8]}
+		n := b.Sub(a).Cross(c.Sub(a))
+		if n.Len() > 0 {
+			n = n.Normalize()
+		}
+		for j := 0; j < 3; j++ {
+			copy(normals[i+j*3:], n[:])
+		}
+	}
+	return normals
+}
+
+// The same normal for every vertex, for flat geometry and lines
+func constantNormals(count int, normal mgl32.Vec3) []float32 {
+	normals := make([]float32, 0, count*3)
+	for i := 0; i < count; i++ {
+		normals = append(normals, normal[0], normal[1], normal[2])
+	}
+	return normals
+}
+
+// Projects the vertices onto the xy plane and maps their bounding rectangle
+// to [0, 1]²
+func planarUVs(vertices []float32) []float32 {
+	count := len(vertices) / 3
+	if count == 0 {
+		return nil
+	}
+	min := mgl32.Vec2{vertices[0], vertices[1]}
+	max := min
+	for i := 0; i < count; i++ {
+		for axis := 0; axis < 2; axis++ {
+			value := vertices[i*3+axis]
+			if value < min[axis] {
+				min[axis] = value
+			}
+			if value > max[axis] {
+				max[axis] = value
+			}
+		}
+	}
+	uvs := make([]float32, 0, count*2)
+	for i := 0; i < count; i++ {
+		var uv [2]float32
+		for axis := 0; axis < 2; axis++ {
+			if max[axis] > min[axis] {
+				uv[axis] = (vertices[i*3+axis] - min[axis]) / (max[axis] - min[axis])
+			}
+		}
+		uvs = append(uvs, uv[0], uv[1])
+	}
+	return uvs
+}
+
+// u runs from 0 to 1 along the length of a line, v is 0
+func lineUVs(vertices []float32) []float32 {
+	count := len(vertices) / 3
+	distances := make([]float32, count)
+	for i := 1; i < count; i++ {
+		a := mgl32.Vec3{vertices[i*3-3], vertices[i*3-2], vertices[i*3-1]}
+		b := mgl32.Vec3{vertices[i*3], vertices[i*3+1], vertices[i*3+2]}
+		distances[i] = distances[i-1] + b.Sub(a).Len()
+	}
+	uvs := make([]float32, 0, count*2)
+	for i := 0; i < count; i++ {
+		u := float32(0)
+		if total := distances[count-1]; total > 0 {
+			u = distances[i] / total
+		}
+		uvs = append(uvs, u, 0)
+	}
+	return uvs
+}
+
+// Projects every vertex along the main axis of its normal and maps the
+// bounding box of the geometry to [0, 1]², e.g. one full texture per cube
+// face
+func boxUVs(vertices, normals []float32) []float32 {
+	count := len(vertices) / 3
+	if count == 0 {
+		return nil
+	}
+	min := mgl32.Vec3{vertices[0], vertices[1], vertices[2]}
+	max := min
+	for i := 0; i < count; i++ {
+		for axis := 0; axis < 3; axis++ {
+			value := vertices[i*3+axis]
+			if value < min[axis] {
+				min[axis] = value
+			}
+			if value > max[axis] {
+				max[axis] = value
+			}
+		}
+	}
+	uvs := make([]float32, 0, count*2)
+	for i := 0; i < count; i++ {
+		main := 0
+		for axis := 1; axis < 3; axis++ {
+			if abs32(normals[i*3+axis]) > abs32(normals[i*3+main]) {
+				main = axis
+			}
+		}
+		var uv [2]float32
+		for j, axis := range [2]int{(main + 1) % 3, (main + 2) % 3} {
+			if max[axis] > min[axis] {
+				uv[j] = (vertices[i*3+axis] - min[axis]) / (max[axis] - min[axis])
+			}
+		}
+		uvs = append(uvs, uv[0], uv[1])
+	}
+	return uvs
+}
+
+func abs32(x float32) float32 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -48,6 +48,7 @@ func StartWorld(window *glfw.Window) (Renderer, *World) {
 
     world := new(World)
     world.Scene = scene
+    world.camera = camera
     world.window = window
     world.program = program
     //&renderer.Start(world)
@@ -92,3 +93,11 @@ func newProgram(vertexShaderSource, fragmentShaderSource string) (uint32, error)
 func (w World) Program() uint32 {
     return w.program
 }
+
+func (w World) Camera() *Camera {
+    return w.camera
+}
+
+func (w World) Window() *glfw.Window {
+    return w.window
+}